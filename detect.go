@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/perangel/dtail/pkg/parser"
+	"github.com/perangel/dtail/pkg/tail"
+)
+
+// autoFormat is the --format value that detects the log format from the first lines of the input
+const autoFormat = "auto"
+
+var (
+	// flag vars
+	detectLines int
+
+	// stdinReader is the decompressed stdin, once opened. Lines sampled to detect the log format
+	// are put back in front of it.
+	stdinReader io.Reader
+)
+
+func init() {
+	dtailCmd.PersistentFlags().IntVar(
+		&detectLines,
+		"detect-lines", 100,
+		"Number of lines sampled from the start of the input to detect the log format with --format auto.",
+	)
+}
+
+// openStdin returns the decompressed stdin
+func openStdin() (io.Reader, error) {
+	if stdinReader == nil {
+		r, err := tail.Decompress(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("stdin: %s", err)
+		}
+		stdinReader = r
+	}
+	return stdinReader, nil
+}
+
+// detectFormat sets the log format to the format detected from the first lines of the input
+// when --format is auto, and prints it.
+func detectFormat(args []string) error {
+	if logFormat != autoFormat {
+		return nil
+	}
+
+	lines, err := sampleLines(inputArgs(args), detectLines)
+	if err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		return fmt.Errorf("failed to detect the log format: no lines to sample, set it with --format")
+	}
+
+	d, err := parser.Detect(lines)
+	if err != nil {
+		return fmt.Errorf("failed to detect the log format: %s, set it with --format", err)
+	}
+
+	best := d.Best()
+	fmt.Printf("\033[0;34mDetected log format: %s (%d of %d sampled lines)\033[0m \n", d.Format, best.Matched, best.Total)
+	logFormat = d.Format
+	return nil
+}
+
+// sampleLines returns up to n lines read from the start of the input. Files are read in the
+// order given (glob patterns are expanded in lexical order), and files that do not exist yet
+// are ignored.
+func sampleLines(args []string, n int) ([]string, error) {
+	if len(args) == 1 && args[0] == stdinPath {
+		return sampleStdin(n)
+	}
+
+	var lines []string
+	for _, arg := range args {
+		paths := []string{arg}
+		if strings.ContainsAny(arg, `*?[\`) {
+			matches, err := filepath.Glob(arg)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s", arg, err)
+			}
+			sort.Strings(matches)
+			paths = matches
+		}
+
+		for _, path := range paths {
+			if len(lines) >= n {
+				return lines, nil
+			}
+
+			sample, err := sampleFile(path, n-len(lines))
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, sample...)
+		}
+	}
+	return lines, nil
+}
+
+// sampleFile returns up to n lines read from the start of the file at path
+func sampleFile(path string, n int) ([]string, error) {
+	src, err := tail.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Stop()
+
+	var lines []string
+	errs := src.Errors()
+	for len(lines) < n {
+		select {
+		case line, ok := <-src.Lines():
+			if !ok {
+				return lines, nil
+			}
+			lines = append(lines, line.Text)
+
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			return nil, err
+		}
+	}
+	return lines, nil
+}
+
+// sampleStdin returns up to n lines read from stdin, which are put back in front of stdin so
+// that they are read again. It blocks until n lines are read or stdin is exhausted.
+func sampleStdin(n int) ([]string, error) {
+	r, err := openStdin()
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		lines   []string
+		sampled bytes.Buffer
+		reader  = bufio.NewReader(r)
+	)
+	for len(lines) < n {
+		b, err := reader.ReadBytes('\n')
+		if len(b) > 0 {
+			sampled.Write(b)
+			lines = append(lines, strings.TrimSuffix(strings.TrimSuffix(string(b), "\n"), "\r"))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("stdin: %s", err)
+		}
+	}
+
+	stdinReader = io.MultiReader(&sampled, reader)
+	return lines, nil
+}
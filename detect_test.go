@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSampleLines(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dtail")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	for name, content := range map[string]string{
+		"a.log": "a1\na2\n",
+		"b.log": "b1\r\nb2\nb3",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("sample files in order", func(t *testing.T) {
+		lines, err := sampleLines([]string{filepath.Join(dir, "b.log"), filepath.Join(dir, "a.log")}, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"b1", "b2", "b3", "a1", "a2"}, lines)
+	})
+
+	t.Run("sample up to n lines", func(t *testing.T) {
+		lines, err := sampleLines([]string{filepath.Join(dir, "*.log")}, 3)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"a1", "a2", "b1"}, lines)
+	})
+
+	t.Run("ignore missing files", func(t *testing.T) {
+		lines, err := sampleLines([]string{filepath.Join(dir, "missing.log"), filepath.Join(dir, "a.log")}, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"a1", "a2"}, lines)
+	})
+}
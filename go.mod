@@ -4,6 +4,7 @@ go 1.16
 
 require (
 	github.com/fsnotify/fsnotify v1.4.9
+	github.com/klauspost/compress v1.15.9
 	github.com/spf13/cobra v1.1.3
 	github.com/stretchr/testify v1.3.0
 	golang.org/x/sys v0.0.0-20210305230114-8fe3ee5dd75b // indirect
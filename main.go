@@ -5,13 +5,11 @@ import (
 	"log"
 	"os"
 	"os/signal"
-	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
-	"github.com/perangel/dtail/pkg/metrics"
-	"github.com/perangel/dtail/pkg/metrics/collections"
-	"github.com/perangel/dtail/pkg/monitor"
+	"github.com/perangel/dtail/pkg/clock"
 	"github.com/perangel/dtail/pkg/parser"
 	"github.com/perangel/dtail/pkg/tail"
 	"github.com/spf13/cobra"
@@ -24,35 +22,59 @@ var (
 	monitorResolution     time.Duration
 	retryFollow           bool
 	reportInterval        time.Duration
+	fromStart             bool
+	lastLines             int
+	startOffset           int64
+	checkpointPath        string
+	checkpointInterval    time.Duration
+	poll                  bool
+	pollInterval          time.Duration
+	logFormat             string
+	formatOptions         []string
 )
 
 const (
 	defaultLogPath = "/tmp/access.log"
+
+	// stdinPath is the FILE argument that reads from stdin
+	stdinPath = "-"
 )
 
 var dtailCmd = &cobra.Command{
-	Use:   "dtail [FILE]",
+	Use:   "dtail [FILE...|-]",
 	Short: "Tail, with more details",
 	Long: `
 dtail is a cli-tool for realtime monitoring of structured log files (e.g. HTTP access log).
+
+Several files, or glob patterns, can be tailed at once. Quote a pattern (e.g.
+'/var/log/nginx/*.log') to also pick up matching files created after startup.
+
+If FILE is "-", or is omitted while stdin is a pipe, lines are read from stdin
+and a final report is printed once the input is exhausted.
+
+Compressed logs (gzip, bzip2 or zstd) are decompressed transparently and read
+once from the start, e.g. 'dtail access.log.2.gz' replays a rotated log and
+exits at the end of the file.
 `,
+	// the replay subcommand would otherwise make cobra reject FILE as an unknown command
+	Args: cobra.ArbitraryArgs,
 	RunE: tailFile,
 }
 
 func init() {
-	dtailCmd.Flags().Float64VarP(
+	dtailCmd.PersistentFlags().Float64VarP(
 		&monitorAlertThreshold,
 		"alert-threshold", "t", 10.0,
 		"Threshold value for triggering an alert during the monitor's alert window.",
 	)
 
-	dtailCmd.Flags().DurationVarP(
+	dtailCmd.PersistentFlags().DurationVarP(
 		&monitorAlertWindow,
 		"alert-window", "w", 2*time.Minute,
 		"Time frame for evaluating a metric against the alert threshold.",
 	)
 
-	dtailCmd.Flags().DurationVarP(
+	dtailCmd.PersistentFlags().DurationVarP(
 		&monitorResolution,
 		"monitor-resolution", "r", 1*time.Second,
 		"Monitor resolution (e.g. 30s, 1m, 5h)",
@@ -64,145 +86,296 @@ func init() {
 		"Retry file after rename or deletion. Similar to `tail -F`.",
 	)
 
-	dtailCmd.Flags().DurationVarP(
+	dtailCmd.Flags().BoolVar(
+		&fromStart,
+		"from-start", false,
+		"Read the file from the beginning instead of only following new lines.",
+	)
+
+	dtailCmd.Flags().IntVarP(
+		&lastLines,
+		"lines", "n", 0,
+		"Start with the last `N` lines of the file. Similar to tail -n.",
+	)
+
+	dtailCmd.Flags().Int64Var(
+		&startOffset,
+		"offset", 0,
+		"Start reading the file at the given byte offset.",
+	)
+
+	dtailCmd.Flags().StringVar(
+		&checkpointPath,
+		"checkpoint", "",
+		"Record the read position in this file and resume from it on restart.",
+	)
+
+	dtailCmd.Flags().DurationVar(
+		&checkpointInterval,
+		"checkpoint-interval", 5*time.Second,
+		"Interval at which the read position is recorded.",
+	)
+
+	dtailCmd.Flags().BoolVar(
+		&poll,
+		"poll", false,
+		"Poll the file for changes instead of relying on filesystem events (e.g. on NFS or docker-for-mac volumes).",
+	)
+
+	dtailCmd.Flags().DurationVar(
+		&pollInterval,
+		"poll-interval", 250*time.Millisecond,
+		"Interval at which the file is polled.",
+	)
+
+	dtailCmd.PersistentFlags().StringVarP(
+		&logFormat,
+		"format", "f", "common",
+		fmt.Sprintf("Log format of the input (one of: %s), or %s to detect it from the first lines.", strings.Join(parser.Formats(), ", "), autoFormat),
+	)
+
+	dtailCmd.PersistentFlags().StringArrayVarP(
+		&formatOptions,
+		"format-option", "o", nil,
+		"Option of the log format, as `key=value`. May be repeated.",
+	)
+
+	dtailCmd.PersistentFlags().DurationVarP(
 		&reportInterval,
 		"report-interval", "i", 10*time.Second,
 		"Print a report at the given interval (e.g. 30s, 1m, 5h)",
 	)
 }
 
-// TODO: Move to DSL/query package
-func total4xxResponses(counterMap collections.CounterMap) int64 {
-	total := int64(0)
-	for k, v := range counterMap {
-		// FIXME: Don't skip key on error
-		i, err := strconv.Atoi(k)
+// isPipe reports whether stdin is connected to a pipe or a file rather than a terminal.
+func isPipe(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice == 0
+}
+
+// tailConfig returns the tail.Config set by the command-line flags
+func tailConfig() (*tail.Config, error) {
+	set := 0
+	for _, isSet := range []bool{fromStart, lastLines > 0, startOffset > 0} {
+		if isSet {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, fmt.Errorf("only one of --from-start, --lines and --offset may be set")
+	}
+
+	config := &tail.Config{
+		Retry:              retryFollow,
+		FromStart:          fromStart,
+		LastLines:          lastLines,
+		Offset:             startOffset,
+		CheckpointInterval: checkpointInterval,
+		Poll:               poll,
+		PollInterval:       pollInterval,
+	}
+
+	if checkpointPath != "" {
+		checkpoints, err := tail.OpenCheckpoints(checkpointPath)
 		if err != nil {
-			continue
+			return nil, fmt.Errorf("failed to open checkpoint: %s", err)
 		}
-		if 400 <= i && i <= 499 {
-			total += v.Value()
+		config.Checkpoints = checkpoints
+	}
+
+	return config, nil
+}
+
+// newParser returns the Parser for the log format set on the command line
+func newParser() (parser.Parser, error) {
+	options := parser.Options{}
+	for _, option := range formatOptions {
+		kv := strings.SplitN(option, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid format option %q, expected key=value", option)
 		}
+		options[kv[0]] = kv[1]
 	}
 
-	return total
+	return parser.New(logFormat, options)
+}
+
+// input describes where dtail reads log lines from
+type input struct {
+	source tail.Source
+	// name describes the input in the startup message
+	name string
+	// multiple is true when lines may be read from more than one file
+	multiple bool
 }
 
-// TODO: Move to DSL/query package
-func total5xxResponses(counterMap collections.CounterMap) int64 {
-	total := int64(0)
-	for k, v := range counterMap {
-		// FIXME: Don't skip key on error
-		i, err := strconv.Atoi(k)
+// inputArgs returns the files to read for the given command-line arguments: stdin if none is
+// given and stdin is a pipe, or the default log file.
+func inputArgs(args []string) []string {
+	if len(args) > 0 {
+		return args
+	}
+	if isPipe(os.Stdin) {
+		return []string{stdinPath}
+	}
+	return []string{defaultLogPath}
+}
+
+// openInput returns the input for the given command-line arguments. The file "-" reads
+// from stdin, as does omitting the file when stdin is a pipe. Several files, or glob
+// patterns, may be given to tail them all at once.
+func openInput(args []string) (*input, error) {
+	args = inputArgs(args)
+
+	if len(args) == 1 && args[0] == stdinPath {
+		stdin, err := openStdin()
 		if err != nil {
-			continue
-		}
-		if 500 <= i && i <= 599 {
-			total += v.Value()
+			return nil, err
 		}
+		return &input{
+			source: tail.NewReader("stdin", stdin),
+			name:   "stdin",
+		}, nil
+	}
+
+	config, err := tailConfig()
+	if err != nil {
+		return nil, err
 	}
 
-	return total
+	src, err := tail.TailGlob(args, config)
+	if err != nil {
+		return nil, err
+	}
 
+	return &input{
+		source:   src,
+		name:     strings.Join(args, ", "),
+		multiple: len(args) > 1 || tail.HasMeta(args[0]),
+	}, nil
 }
 
 func tailFile(cmd *cobra.Command, args []string) error {
-	var filepath string
-	if len(args) < 1 {
-		filepath = defaultLogPath
-	} else {
-		filepath = args[0]
+	if err := detectFormat(args); err != nil {
+		return err
 	}
 
-	t, err := tail.TailFile(filepath, &tail.Config{Retry: retryFollow})
+	logParser, err := newParser()
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("\033[0;34mTailing file %s...\033[0m \n", filepath)
+	in, err := openInput(args)
+	if err != nil {
+		return err
+	}
+	src := in.source
 
-	// create a monitor for request rate
-	requestRateMonitor := monitor.NewMonitor(&monitor.Config{
-		Aggregator:     monitor.Mean, // TODO: accept aggregation on the command line
-		AlertThreshold: monitorAlertThreshold,
-		Resolution:     monitorResolution,
-		Window:         monitorAlertWindow,
-	})
+	fmt.Printf("\033[0;34mTailing %s...\033[0m \n", in.name)
 
 	shutdownCh := make(chan os.Signal, 1)
 	signal.Notify(shutdownCh, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-shutdownCh
-		t.Stop()
-		requestRateMonitor.Stop()
-	}()
 
-	// TODO: Refactor this to pkg/dtail
-	go func() {
-
-		// NOTE: `requestCounter` will be reset at each tick of the Monitor interval,
-		// so DO NOT rely on it for aggregate totals during the execution of the program.
-		requestCounter := metrics.NewCounter()
-		requestRateMonitor.Watch(requestCounter)
-
-		// count total requests handled by dtail
-		totalRequests := metrics.NewCounter()
-		requestsByUser := collections.NewCounterMap()
-		requestsByIP := collections.NewCounterMap()
-		requestsBySection := collections.NewCounterMap()
-		requestsByURI := collections.NewCounterMap()
-		requestsByStatusCode := collections.NewCounterMap()
-
-		parser := parser.NewParser()
-		reportTick := time.NewTicker(reportInterval)
-		for {
-			select {
-			case line := <-t.Lines:
-				request, err := parser.ParseLine(line)
-				if err != nil {
-					log.Println("parser error: ", err)
-				}
-
-				requestCounter.Inc(1)
-
-				requestsByUser.IncKey(request.AuthUser)
-				requestsByIP.IncKey(request.RemoteHost)
-				requestsBySection.IncKey(request.Section())
-				requestsByURI.IncKey(request.URI)
-				requestsByStatusCode.IncKey(fmt.Sprintf("%d", request.StatusCode))
-				totalRequests.Inc(1)
-
-			case evt := <-requestRateMonitor.Triggered:
-				fmt.Printf("\033[0;31mHigh traffic generated an alert - hits = %.2f, triggered at %v\033[0m \n", evt.Value, evt.Time)
-
-			case evt := <-requestRateMonitor.Resolved:
-				fmt.Printf("\033[0;32mHigh traffic alert resolved - hits = %.2f, resolved at %v\033[0m \n", evt.Value, evt.Time)
-
-			case t := <-reportTick.C:
-				fmt.Println()
-				fmt.Println("Traffic Report:")
-				fmt.Printf("   Current time: %v\n", t)
-				fmt.Printf("   Total Requests: %d\n", totalRequests.Value())
-				fmt.Printf("   Top 3 IPs by # of requests: %v\n", requestsByIP.TopNKeys(3))
-				fmt.Printf("   Top 3 users by # of requests: %v\n", requestsByUser.TopNKeys(3))
-				fmt.Printf("   Top 3 site sections by # of requests: %v\n", requestsBySection.TopNKeys(3))
-				fmt.Printf("   Top 3 URIs by # of requests: %v\n", requestsByURI.TopNKeys(3))
-				fmt.Printf("   No. of 4xx responses: %v\n", total4xxResponses(requestsByStatusCode))
-				fmt.Printf("   No. of 5xx responses: %v\n", total5xxResponses(requestsByStatusCode))
-				fmt.Println()
-
-				// Reset all of the counters
-				totalRequests.Reset()
-				requestsByIP.Reset()
-				requestsByUser.Reset()
-				requestsBySection.Reset()
-				requestsByURI.Reset()
-				requestsByStatusCode.Reset()
+	clk := clock.Real{}
+	requestRateMonitors := newRequestRateMonitors(in.multiple, clk)
+	report := newTrafficReport(in.multiple)
+
+	// finish evaluates the monitors one last time and prints a final report for
+	// whatever was collected since the last report tick.
+	finish := func() {
+		requestRateMonitors.flush()
+		report.print(clk.Now())
+	}
+
+	// parsers holds a parser per source, as parsers may hold the state of the log they parse
+	// (e.g. the columns of W3C logs)
+	parsers := map[string]parser.Parser{}
+	sourceParser := func(source string) (parser.Parser, error) {
+		if p, ok := parsers[source]; ok {
+			return p, nil
+		}
+
+		p := logParser
+		if len(parsers) > 0 {
+			var err error
+			if p, err = newParser(); err != nil {
+				return nil, err
 			}
 		}
-	}()
+		parsers[source] = p
+		return p, nil
+	}
 
-	return t.Wait()
+	// failed is the last error of a file that is not read anymore, while the others are
+	var failed error
+
+	// TODO: Refactor this to pkg/dtail
+	reportTick, stopReports := scheduleReports(clk, reportInterval)
+	defer stopReports()
+	errs := src.Errors()
+	for {
+		select {
+		case line, ok := <-src.Lines():
+			if !ok {
+				finish()
+				return failed
+			}
+
+			p, err := sourceParser(line.Source)
+			if err != nil {
+				src.Stop()
+				return err
+			}
+
+			request, err := p.ParseLine(line.Text)
+			if err == parser.ErrSkipLine {
+				continue
+			}
+			if err != nil {
+				log.Println("parser error: ", err)
+				continue
+			}
+
+			requestRateMonitors.inc(line.Source)
+			report.record(line.Source, request)
+
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			if tail.IsNotice(err) {
+				fmt.Printf("\033[0;33m%s\033[0m \n", err)
+				continue
+			}
+			if in.multiple {
+				// the file is not read anymore, but the others are
+				fmt.Printf("\033[0;31m%s, no longer reading it\033[0m \n", err)
+				failed = err
+				continue
+			}
+			src.Stop()
+			return err
+
+		case evt := <-requestRateMonitors.Triggered:
+			printTriggered(evt)
+
+		case evt := <-requestRateMonitors.Resolved:
+			printResolved(evt)
+
+		case t := <-reportTick:
+			report.print(t)
+			report.reset()
+
+		case <-shutdownCh:
+			src.Stop()
+			finish()
+			return nil
+		}
+	}
 }
 
 func main() {
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDtailCmd(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dtail")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	t.Run("tail a file given as argument", func(t *testing.T) {
+		// compressed logs are read once, so that the command returns at the end of the file
+		path := filepath.Join(dir, "access.log.gz")
+		f, err := os.Create(path)
+		if !assert.NoError(t, err) {
+			return
+		}
+		w := gzip.NewWriter(f)
+		w.Write([]byte(`127.0.0.1 - james [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200 123` + "\n"))
+		w.Close()
+		f.Close()
+
+		dtailCmd.SetArgs([]string{path})
+		assert.NoError(t, dtailCmd.Execute())
+	})
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/perangel/dtail/pkg/clock"
+	"github.com/perangel/dtail/pkg/metrics"
+	"github.com/perangel/dtail/pkg/monitor"
+)
+
+// rateMonitor is a monitor watching a request counter
+type rateMonitor struct {
+	monitor *monitor.Monitor
+	// NOTE: `counter` will be reset at each tick of the Monitor interval,
+	// so DO NOT rely on it for aggregate totals during the execution of the program.
+	counter *metrics.Counter
+}
+
+// requestRateMonitors watches the request rate in aggregate and, if enabled, for each
+// source individually. All of the monitors deliver their events on the same channels.
+type requestRateMonitors struct {
+	Triggered chan *monitor.Event
+	Resolved  chan *monitor.Event
+
+	aggregate *rateMonitor
+	perSource bool
+	bySource  map[string]*rateMonitor
+
+	// clock drives the monitors
+	clock clock.Clock
+}
+
+func newRequestRateMonitors(perSource bool, clk clock.Clock) *requestRateMonitors {
+	r := &requestRateMonitors{
+		Triggered: make(chan *monitor.Event, monitor.EventBufferSize),
+		Resolved:  make(chan *monitor.Event, monitor.EventBufferSize),
+		perSource: perSource,
+		bySource:  make(map[string]*rateMonitor),
+		clock:     clk,
+	}
+	r.aggregate = r.newMonitor("")
+	return r
+}
+
+func (r *requestRateMonitors) newMonitor(name string) *rateMonitor {
+	m := &rateMonitor{
+		monitor: monitor.NewMonitor(&monitor.Config{
+			Aggregator:     monitor.Mean, // TODO: accept aggregation on the command line
+			AlertThreshold: monitorAlertThreshold,
+			Resolution:     monitorResolution,
+			Window:         monitorAlertWindow,
+			Name:           name,
+			Triggered:      r.Triggered,
+			Resolved:       r.Resolved,
+			Clock:          r.clock,
+		}),
+		counter: metrics.NewCounter(),
+	}
+	m.monitor.Watch(m.counter)
+	return m
+}
+
+// inc counts a request read from the given source
+func (r *requestRateMonitors) inc(source string) {
+	r.aggregate.counter.Inc(1)
+	if !r.perSource {
+		return
+	}
+
+	m, ok := r.bySource[source]
+	if !ok {
+		m = r.newMonitor(source)
+		r.bySource[source] = m
+	}
+	m.counter.Inc(1)
+}
+
+// flush flushes every monitor, printing any resulting alert. Every monitor is stopped first,
+// so that the events of the monitors still ticking cannot fill the channels meanwhile.
+func (r *requestRateMonitors) flush() {
+	monitors := []*rateMonitor{r.aggregate}
+	for _, m := range r.bySource {
+		monitors = append(monitors, m)
+	}
+
+	for _, m := range monitors {
+		m.monitor.Stop()
+	}
+	r.printPending()
+
+	for _, m := range monitors {
+		m.monitor.Flush()
+		r.printPending()
+	}
+}
+
+// printPending prints the events already delivered on the channels without blocking
+func (r *requestRateMonitors) printPending() {
+	for {
+		select {
+		case evt := <-r.Triggered:
+			printTriggered(evt)
+		case evt := <-r.Resolved:
+			printResolved(evt)
+		default:
+			return
+		}
+	}
+}
+
+// eventSubject describes what a monitor event refers to
+func eventSubject(evt *monitor.Event) string {
+	if evt.Name == "" {
+		return "High traffic"
+	}
+	return fmt.Sprintf("High traffic on %s", evt.Name)
+}
+
+func printTriggered(evt *monitor.Event) {
+	fmt.Printf("\033[0;31m%s generated an alert - hits = %.2f, triggered at %v\033[0m \n", eventSubject(evt), evt.Value, evt.Time)
+}
+
+func printResolved(evt *monitor.Event) {
+	fmt.Printf("\033[0;32m%s alert resolved - hits = %.2f, resolved at %v\033[0m \n", eventSubject(evt), evt.Value, evt.Time)
+}
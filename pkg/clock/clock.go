@@ -0,0 +1,52 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time and runs periodic work. It allows time to be driven by something
+// other than the wall clock, e.g. by the timestamps of a log being replayed.
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+	// Every calls f with the current time at every interval d, until the returned
+	// function is called. Once the returned function returns, f is not running and is not
+	// called again.
+	Every(d time.Duration, f func(time.Time)) (stop func())
+}
+
+// Real is a Clock backed by the wall clock. Periodic work runs on its own goroutine.
+type Real struct{}
+
+// Now returns the current wall clock time
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+// Every calls f from a new goroutine at every interval d, until the returned function is called.
+// Stopping waits for a call of f in progress to return, so it must not be called from f.
+func (Real) Every(d time.Duration, f func(time.Time)) func() {
+	ticker := time.NewTicker(d)
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+
+	go func() {
+		defer close(doneCh)
+		defer ticker.Stop()
+		for {
+			select {
+			case t := <-ticker.C:
+				f(t)
+			case <-stopCh:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(stopCh) })
+		<-doneCh
+	}
+}
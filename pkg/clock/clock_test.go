@@ -0,0 +1,52 @@
+package clock
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReal(t *testing.T) {
+	t.Run("every calls f until stopped", func(t *testing.T) {
+		calls := make(chan time.Time, 10)
+		stop := Real{}.Every(time.Millisecond, func(now time.Time) {
+			select {
+			case calls <- now:
+			default:
+			}
+		})
+
+		select {
+		case <-calls:
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for a call")
+		}
+
+		stop()
+		stop()
+	})
+
+	t.Run("stop waits for the call in progress", func(t *testing.T) {
+		started := make(chan struct{}, 1)
+		var running int32
+		stop := Real{}.Every(time.Millisecond, func(time.Time) {
+			atomic.StoreInt32(&running, 1)
+			select {
+			case started <- struct{}{}:
+			default:
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.StoreInt32(&running, 0)
+		})
+
+		<-started
+		stop()
+		assert.Equal(t, int32(0), atomic.LoadInt32(&running), "the call in progress should have returned")
+	})
+
+	t.Run("now is the wall clock time", func(t *testing.T) {
+		assert.WithinDuration(t, time.Now(), Real{}.Now(), time.Second)
+	})
+}
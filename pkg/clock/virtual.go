@@ -0,0 +1,103 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Virtual is a Clock whose time only moves when it is set. Periodic work runs synchronously,
+// on the goroutine that moves the clock, so that its effects are observable as soon as
+// Set, Advance or Step returns. This makes Virtual suitable as a fake clock in tests, e.g.
+// to evaluate a Monitor over minutes of traffic in a few milliseconds.
+type Virtual struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*timer
+}
+
+// timer is a function scheduled to run periodically on a Virtual clock
+type timer struct {
+	next     time.Time
+	interval time.Duration
+	f        func(time.Time)
+}
+
+// NewVirtual returns a Virtual clock set to the given time
+func NewVirtual(now time.Time) *Virtual {
+	return &Virtual{now: now}
+}
+
+// Now returns the current time of the clock
+func (v *Virtual) Now() time.Time {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.now
+}
+
+// Every schedules f to be called at every interval d, starting at d from the current time.
+func (v *Virtual) Every(d time.Duration, f func(time.Time)) func() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	t := &timer{next: v.now.Add(d), interval: d, f: f}
+	v.timers = append(v.timers, t)
+
+	return func() {
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		for i, other := range v.timers {
+			if other == t {
+				v.timers = append(v.timers[:i], v.timers[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Step runs the earliest scheduled call due at or before until, moving the clock to the
+// time of that call. It returns false, leaving the clock untouched, if no call is due.
+//
+// Stepping allows the caller to observe the effects of each call (e.g. to drain the events
+// emitted by a Monitor) before the next one is made.
+func (v *Virtual) Step(until time.Time) bool {
+	v.mu.Lock()
+	var next *timer
+	for _, t := range v.timers {
+		if !t.next.After(until) && (next == nil || t.next.Before(next.next)) {
+			next = t
+		}
+	}
+	if next == nil {
+		v.mu.Unlock()
+		return false
+	}
+
+	now := next.next
+	if now.After(v.now) {
+		v.now = now
+	}
+	next.next = now.Add(next.interval)
+	v.mu.Unlock()
+
+	// the lock is released so that f can use the clock
+	next.f(now)
+	return true
+}
+
+// Set moves the clock to t, running every scheduled call due at or before t in order.
+// The clock never moves backwards: if t is before the current time, Set does nothing.
+func (v *Virtual) Set(t time.Time) {
+	for v.Step(t) {
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if t.After(v.now) {
+		v.now = t
+	}
+}
+
+// Advance moves the clock forward by d, running every scheduled call due in the meantime
+func (v *Virtual) Advance(d time.Duration) {
+	v.Set(v.Now().Add(d))
+}
@@ -0,0 +1,86 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVirtual(t *testing.T) {
+	start := time.Date(2018, 5, 9, 16, 0, 0, 0, time.UTC)
+
+	t.Run("set runs every call due in order", func(t *testing.T) {
+		clk := NewVirtual(start)
+		calls := []string{}
+		clk.Every(2*time.Second, func(now time.Time) {
+			calls = append(calls, "2s@"+now.Sub(start).String())
+		})
+		clk.Every(3*time.Second, func(now time.Time) {
+			calls = append(calls, "3s@"+now.Sub(start).String())
+		})
+
+		clk.Set(start.Add(6 * time.Second))
+		assert.Equal(t, []string{"2s@2s", "3s@3s", "2s@4s", "2s@6s", "3s@6s"}, calls)
+		assert.Equal(t, start.Add(6*time.Second), clk.Now())
+	})
+
+	t.Run("calls observe the time they are due at", func(t *testing.T) {
+		clk := NewVirtual(start)
+		var seen time.Time
+		clk.Every(time.Second, func(time.Time) {
+			seen = clk.Now()
+		})
+
+		clk.Set(start.Add(1500 * time.Millisecond))
+		assert.Equal(t, start.Add(time.Second), seen)
+	})
+
+	t.Run("step runs one call at a time", func(t *testing.T) {
+		clk := NewVirtual(start)
+		calls := 0
+		clk.Every(time.Second, func(time.Time) {
+			calls++
+		})
+
+		until := start.Add(2 * time.Second)
+		assert.True(t, clk.Step(until))
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, start.Add(time.Second), clk.Now())
+		assert.True(t, clk.Step(until))
+		assert.False(t, clk.Step(until))
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("stopped calls are not run", func(t *testing.T) {
+		clk := NewVirtual(start)
+		calls := 0
+		stop := clk.Every(time.Second, func(time.Time) {
+			calls++
+		})
+
+		clk.Set(start.Add(time.Second))
+		stop()
+		clk.Set(start.Add(5 * time.Second))
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("advance moves the clock forward", func(t *testing.T) {
+		clk := NewVirtual(start)
+		calls := 0
+		clk.Every(time.Second, func(time.Time) {
+			calls++
+		})
+
+		clk.Advance(time.Second)
+		clk.Advance(1500 * time.Millisecond)
+		assert.Equal(t, start.Add(2500*time.Millisecond), clk.Now())
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("never moves backwards", func(t *testing.T) {
+		clk := NewVirtual(start)
+		clk.Set(start.Add(-time.Minute))
+		assert.Equal(t, start, clk.Now())
+	})
+}
@@ -31,6 +31,15 @@ func (c CounterMap) IncKey(key string) {
 	}
 }
 
+// AddKey adds i to the counter stored at a given key
+func (c CounterMap) AddKey(key string, i int64) {
+	if _, ok := c[key]; !ok {
+		c[key] = metrics.NewCounterWithValue(i)
+	} else {
+		c[key].Inc(i)
+	}
+}
+
 // TopNKeys returns the top N keys with the highest values in the map in descending order
 func (c CounterMap) TopNKeys(n int) []string {
 	revMap := c.reverseMap()
@@ -25,6 +25,15 @@ func TestCounterMap(t *testing.T) {
 		assert.Equal(t, []string{"sally", "bob", "mary", "jill", "jack"}, top)
 	})
 
+	t.Run("add to the counter stored at a key", func(t *testing.T) {
+		cm := NewCounterMap()
+		cm.AddKey("jack", 100)
+		cm.AddKey("jack", 50)
+		cm.AddKey("jill", 10)
+		assert.Equal(t, int64(150), cm["jack"].Value())
+		assert.Equal(t, int64(10), cm["jill"].Value())
+	})
+
 	t.Run("reset all counters in the map", func(t *testing.T) {
 		cm := NewCounterMap()
 		cm["jack"] = metrics.NewCounterWithValue(100)
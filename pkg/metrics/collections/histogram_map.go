@@ -0,0 +1,24 @@
+package collections
+
+import "github.com/perangel/dtail/pkg/metrics"
+
+// HistogramMap is a collection map of Histograms
+type HistogramMap map[string]*metrics.Histogram
+
+// NewHistogramMap returns a new HistogramMap
+func NewHistogramMap() HistogramMap {
+	return make(HistogramMap)
+}
+
+// ObserveKey records a value in the histogram stored at a given key
+func (h HistogramMap) ObserveKey(key string, v int64) {
+	if _, ok := h[key]; !ok {
+		h[key] = metrics.NewHistogram()
+	}
+	h[key].Observe(v)
+}
+
+// Reset clears the map
+func (h *HistogramMap) Reset() {
+	*h = NewHistogramMap()
+}
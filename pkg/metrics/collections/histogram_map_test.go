@@ -0,0 +1,26 @@
+package collections
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogramMap(t *testing.T) {
+	t.Run("observe values at a key", func(t *testing.T) {
+		hm := NewHistogramMap()
+		hm.ObserveKey("/api", 10)
+		hm.ObserveKey("/api", 20)
+		hm.ObserveKey("/report", 5)
+		assert.Equal(t, int64(2), hm["/api"].Count())
+		assert.Equal(t, int64(20), hm["/api"].Max())
+		assert.Equal(t, int64(1), hm["/report"].Count())
+	})
+
+	t.Run("reset all histograms in the map", func(t *testing.T) {
+		hm := NewHistogramMap()
+		hm.ObserveKey("/api", 10)
+		hm.Reset()
+		assert.Empty(t, hm)
+	})
+}
@@ -19,12 +19,12 @@ func NewCounterWithValue(i int64) *Counter {
 
 // Value returns the current value of the Counter
 func (c *Counter) Value() int64 {
-	return c.i
+	return atomic.LoadInt64(&c.i)
 }
 
 // Inc increments a Counter by a specified amount
 func (c *Counter) Inc(i int64) {
-	atomic.AddInt64(&c.i, 1)
+	atomic.AddInt64(&c.i, i)
 }
 
 // Add adds the value of another Counter
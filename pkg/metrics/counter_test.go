@@ -33,6 +33,12 @@ func TestCounter(t *testing.T) {
 		c.Inc(1)
 		assert.Equal(t, int64(1), c.Value(), "value should have been incremented by 1")
 	})
+
+	t.Run("increment counter by an amount", func(t *testing.T) {
+		c := NewCounter()
+		c.Inc(5)
+		assert.Equal(t, int64(5), c.Value(), "value should have been incremented by 5")
+	})
 }
 
 func TestCounterImplementsMetricIface(t *testing.T) {
@@ -0,0 +1,224 @@
+package metrics
+
+import (
+	"math"
+	"math/bits"
+	"sort"
+	"sync"
+)
+
+// histogramSubBits sets the precision of a Histogram: each power of two is split into
+// 2^histogramSubBits buckets, i.e. values are recorded with a relative error under 1%.
+const histogramSubBits = 7
+
+// Histogram records the distribution of int64 values (e.g. request durations in nanoseconds)
+// in log-linear buckets, so that quantiles are estimated within 1% of the recorded values, in
+// constant memory per order of magnitude. Values lower than 256 are recorded exactly, and
+// negative values are ignored.
+//
+// Histogram implements Observable: Add merges the buckets of another Histogram, and the Float
+// value of a Histogram is one of its quantiles (the median by default, see
+// NewHistogramWithQuantile). A Monitor watching a Histogram with the Sum aggregator evaluates
+// the quantile of all of the values recorded over its window, e.g. the 99th percentile latency
+// of the last 2 minutes.
+type Histogram struct {
+	mu      sync.Mutex
+	buckets map[int]int64
+	count   int64
+	sum     int64
+	max     int64
+
+	// quantile is the quantile reported by Float
+	quantile float64
+}
+
+// NewHistogram returns a new, empty, Histogram whose Float value is its median
+func NewHistogram() *Histogram {
+	return NewHistogramWithQuantile(0.5)
+}
+
+// NewHistogramWithQuantile returns a new, empty, Histogram whose Float value is its q-quantile
+// (e.g. 0.99 for the 99th percentile)
+func NewHistogramWithQuantile(q float64) *Histogram {
+	return &Histogram{buckets: make(map[int]int64), quantile: q}
+}
+
+// Observe records a value
+func (h *Histogram) Observe(v int64) {
+	if v < 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.buckets[bucketIndex(v)]++
+	h.count++
+	h.sum += v
+	if v > h.max {
+		h.max = v
+	}
+}
+
+// Count returns the number of recorded values
+func (h *Histogram) Count() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+// Sum returns the sum of the recorded values
+func (h *Histogram) Sum() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.sum
+}
+
+// Max returns the highest recorded value, or 0 if the Histogram is empty
+func (h *Histogram) Max() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.max
+}
+
+// Quantile returns an estimate of the q-quantile of the recorded values (e.g. 0.99 for the
+// 99th percentile), or 0 if the Histogram is empty. Quantiles falling in the bucket of the
+// highest value are reported as the highest value.
+func (h *Histogram) Quantile(q float64) int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.quantileLocked(q)
+}
+
+// quantileLocked returns the q-quantile of the recorded values, with the lock held
+func (h *Histogram) quantileLocked(q float64) int64 {
+	if h.count == 0 {
+		return 0
+	}
+
+	// rank is the number of values lower than or equal to the quantile
+	rank := int64(math.Ceil(q * float64(h.count)))
+	if rank < 1 {
+		rank = 1
+	}
+
+	indexes := make([]int, 0, len(h.buckets))
+	for i := range h.buckets {
+		indexes = append(indexes, i)
+	}
+	sort.Ints(indexes)
+
+	seen := int64(0)
+	for _, i := range indexes {
+		seen += h.buckets[i]
+		if seen < rank {
+			continue
+		}
+		if i == bucketIndex(h.max) {
+			return h.max
+		}
+		return bucketValue(i)
+	}
+	return h.max
+}
+
+// Add merges the values recorded by another Histogram
+func (h *Histogram) Add(other Observable) {
+	o := other.(*Histogram).snapshot()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, n := range o.buckets {
+		h.buckets[i] += n
+	}
+	h.count += o.count
+	h.sum += o.sum
+	if o.max > h.max {
+		h.max = o.max
+	}
+}
+
+// Multiply scales the recorded values by the Float value of another Observable (e.g. to convert
+// their unit). Negative factors are ignored.
+func (h *Histogram) Multiply(other Observable) {
+	f := other.Float()
+	if f < 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	buckets := make(map[int]int64, len(h.buckets))
+	for i, n := range h.buckets {
+		buckets[bucketIndex(int64(float64(bucketValue(i))*f))] += n
+	}
+	h.buckets = buckets
+	h.sum = int64(float64(h.sum) * f)
+	h.max = int64(float64(h.max) * f)
+}
+
+// Less compares the Float value of self to the Float value of another Observable, e.g. an alert
+// threshold
+func (h *Histogram) Less(other Observable) bool {
+	return h.Float() < other.Float()
+}
+
+// Reset removes all of the recorded values
+func (h *Histogram) Reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.buckets = make(map[int]int64)
+	h.count = 0
+	h.sum = 0
+	h.max = 0
+}
+
+// Clone returns a copy of a Histogram
+func (h *Histogram) Clone() Observable {
+	return h.snapshot()
+}
+
+// Float returns the quantile of the Histogram set by NewHistogramWithQuantile
+func (h *Histogram) Float() float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return float64(h.quantileLocked(h.quantile))
+}
+
+// snapshot returns a copy of a Histogram
+func (h *Histogram) snapshot() *Histogram {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	c := &Histogram{
+		buckets:  make(map[int]int64, len(h.buckets)),
+		count:    h.count,
+		sum:      h.sum,
+		max:      h.max,
+		quantile: h.quantile,
+	}
+	for i, n := range h.buckets {
+		c.buckets[i] = n
+	}
+	return c
+}
+
+// bucketIndex returns the index of the bucket of a value. Values up to 2^(histogramSubBits+1)
+// have a bucket of their own, and each following power of two is split in 2^histogramSubBits
+// buckets.
+func bucketIndex(v int64) int {
+	shift := bits.Len64(uint64(v)) - histogramSubBits - 1
+	if shift <= 0 {
+		return int(v)
+	}
+	return shift<<histogramSubBits + int(v>>uint(shift))
+}
+
+// bucketValue returns the value representing a bucket, the middle of the values it holds
+func bucketValue(i int) int64 {
+	shift := i>>histogramSubBits - 1
+	if shift <= 0 {
+		return int64(i)
+	}
+	lower := int64(i-shift<<histogramSubBits) << uint(shift)
+	return lower + (int64(1)<<uint(shift)-1)/2
+}
@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistogram(t *testing.T) {
+	t.Run("initialize new Histogram", func(t *testing.T) {
+		h := NewHistogram()
+		assert.Equal(t, int64(0), h.Count())
+		assert.Equal(t, int64(0), h.Max())
+		assert.Equal(t, int64(0), h.Quantile(0.5))
+	})
+
+	t.Run("record small values exactly", func(t *testing.T) {
+		h := NewHistogram()
+		for v := int64(1); v <= 100; v++ {
+			h.Observe(v)
+		}
+		assert.Equal(t, int64(100), h.Count())
+		assert.Equal(t, int64(100), h.Max())
+		assert.Equal(t, int64(1), h.Quantile(0))
+		assert.Equal(t, int64(50), h.Quantile(0.5))
+		assert.Equal(t, int64(90), h.Quantile(0.9))
+		assert.Equal(t, int64(99), h.Quantile(0.99))
+		assert.Equal(t, int64(100), h.Quantile(1))
+	})
+
+	t.Run("estimate quantiles of large values within 1%", func(t *testing.T) {
+		h := NewHistogram()
+		for v := int64(1); v <= 10000; v++ {
+			h.Observe(v * 1000)
+		}
+		for _, q := range []float64{0.5, 0.9, 0.99} {
+			expected := q * 10000 * 1000
+			assert.InEpsilon(t, expected, float64(h.Quantile(q)), 0.01, "quantile %v", q)
+		}
+		assert.Equal(t, int64(10000*1000), h.Max())
+		assert.Equal(t, int64(10000*1000), h.Quantile(1))
+	})
+
+	t.Run("ignore negative values", func(t *testing.T) {
+		h := NewHistogram()
+		h.Observe(-1)
+		assert.Equal(t, int64(0), h.Count())
+	})
+
+	t.Run("sum recorded values", func(t *testing.T) {
+		h := NewHistogram()
+		h.Observe(1000)
+		h.Observe(234)
+		assert.Equal(t, int64(1234), h.Sum())
+	})
+
+	t.Run("reset a histogram", func(t *testing.T) {
+		h := NewHistogram()
+		h.Observe(10)
+		h.Reset()
+		assert.Equal(t, int64(0), h.Count())
+		assert.Equal(t, int64(0), h.Sum())
+		assert.Equal(t, int64(0), h.Max())
+		assert.Equal(t, int64(0), h.Quantile(0.99))
+	})
+}
+
+func TestHistogramImplementsMetricIface(t *testing.T) {
+	t.Run("add two histograms", func(t *testing.T) {
+		h1 := NewHistogram()
+		h2 := NewHistogram()
+		for v := int64(1); v <= 50; v++ {
+			h1.Observe(v)
+			h2.Observe(v + 50)
+		}
+		h1.Add(h2)
+		assert.Equal(t, int64(100), h1.Count())
+		assert.Equal(t, int64(5050), h1.Sum())
+		assert.Equal(t, int64(100), h1.Max())
+		assert.Equal(t, int64(50), h1.Quantile(0.5))
+		assert.Equal(t, int64(90), h1.Quantile(0.9))
+		assert.Equal(t, int64(50), h2.Count(), "adding should not change the other histogram")
+	})
+
+	t.Run("multiply a histogram", func(t *testing.T) {
+		h := NewHistogram()
+		h.Observe(10)
+		h.Observe(20)
+		factor := Float(2)
+		h.Multiply(&factor)
+		assert.Equal(t, int64(2), h.Count())
+		assert.Equal(t, int64(60), h.Sum())
+		assert.Equal(t, int64(40), h.Max())
+		assert.Equal(t, int64(20), h.Quantile(0.5))
+	})
+
+	t.Run("float value is the configured quantile", func(t *testing.T) {
+		h := NewHistogramWithQuantile(0.99)
+		m := NewHistogram()
+		for v := int64(1); v <= 100; v++ {
+			h.Observe(v)
+			m.Observe(v)
+		}
+		assert.Equal(t, 99.0, h.Float())
+		assert.Equal(t, 50.0, m.Float(), "float value should default to the median")
+	})
+
+	t.Run("compare a histogram to a threshold", func(t *testing.T) {
+		h := NewHistogram()
+		h.Observe(10)
+		low, high := Float(5), Float(20)
+		assert.True(t, h.Less(&high))
+		assert.False(t, h.Less(&low))
+	})
+
+	t.Run("clone a histogram", func(t *testing.T) {
+		h1 := NewHistogramWithQuantile(0.9)
+		h1.Observe(10)
+		h2 := h1.Clone().(*Histogram)
+		assert.Equal(t, h1.Count(), h2.Count())
+		assert.Equal(t, h1.Float(), h2.Float())
+		h1.Observe(20)
+		assert.Equal(t, int64(1), h2.Count(), "recording in source histogram should not record in copy")
+	})
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	t.Run("bucket values are within 1% of the values", func(t *testing.T) {
+		for _, v := range []int64{0, 1, 127, 128, 255, 256, 257, 1000, 123456, 1 << 40, 1<<62 + 12345} {
+			i := bucketIndex(v)
+			if v < 256 {
+				assert.Equal(t, v, bucketValue(i))
+				continue
+			}
+			assert.InEpsilon(t, float64(v), float64(bucketValue(i)), 0.01, "value %d", v)
+		}
+	})
+
+	t.Run("buckets are ordered like their values", func(t *testing.T) {
+		prev := -1
+		for v := int64(0); v < 1<<16; v += 7 {
+			i := bucketIndex(v)
+			assert.True(t, i >= prev, "value %d", v)
+			prev = i
+		}
+	})
+}
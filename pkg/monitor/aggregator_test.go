@@ -34,3 +34,27 @@ func TestAggregatorOverCounters(t *testing.T) {
 		assert.Equal(t, 5.0, max.Float())
 	})
 }
+
+func TestAggregatorOverHistograms(t *testing.T) {
+	data := make(metrics.Observables, 5)
+
+	for i := range data {
+		h := metrics.NewHistogramWithQuantile(0.9)
+		for v := int64(1); v <= 20; v++ {
+			h.Observe(int64(i)*20 + v)
+		}
+		data[i] = h
+	}
+
+	t.Run("calculate sum over a collection of histograms", func(t *testing.T) {
+		sum := Sum(data).(*metrics.Histogram)
+		assert.Equal(t, int64(100), sum.Count())
+		assert.Equal(t, 90.0, sum.Float(), "sum should be the quantile of the merged histograms")
+		assert.Equal(t, int64(20), data[0].(*metrics.Histogram).Count(), "sum should not change the first histogram")
+	})
+
+	t.Run("calculate max over a collection of histograms", func(t *testing.T) {
+		max := Max(data)
+		assert.Equal(t, 98.0, max.Float())
+	})
+}
@@ -1,8 +1,10 @@
 package monitor
 
 import (
+	"sync"
 	"time"
 
+	"github.com/perangel/dtail/pkg/clock"
 	"github.com/perangel/dtail/pkg/metrics"
 )
 
@@ -14,9 +16,19 @@ type Config struct {
 	Window time.Duration
 	// An aggregation function (e.g. Mean, Min, Max, Sum, etc)
 	// For available aggregator functions see aggregator.go
+	// Sum merges metrics.Histogram datapoints, to evaluate a quantile over the whole window
 	Aggregator aggregator
 	// Threshold value for triggering an alert
 	AlertThreshold float64
+	// Name identifies the Monitor in the events it emits (e.g. the log file it watches)
+	Name string
+	// Triggered and Resolved, if set, are the channels on which the Monitor delivers its events,
+	// which allows several monitors to share them. If nil, the Monitor creates its own.
+	Triggered chan *Event
+	Resolved  chan *Event
+	// Clock drives the resolution ticks and timestamps the events (default: the wall clock).
+	// A virtual clock allows a Monitor to evaluate historical data at the pace it was logged.
+	Clock clock.Clock
 }
 
 // monitorEventType is the type of event emitted by the monitor
@@ -29,8 +41,13 @@ const (
 	EventTypeResolved monitorEventType = "resolved"
 )
 
+// EventBufferSize is the capacity of the Triggered and Resolved channels created by NewMonitor.
+// Buffering allows Flush to be called from the goroutine that consumes the events.
+const EventBufferSize = 8
+
 // Event represents a monitor event (e.g. Triggered, Resovled)
 type Event struct {
+	Name  string
 	Type  monitorEventType
 	Value float64
 	Time  time.Time
@@ -49,6 +66,7 @@ type Event struct {
 //
 // Monitor is modeled after a DataDog monitor
 type Monitor struct {
+	Name      string
 	Triggered chan *Event
 	Resolved  chan *Event
 
@@ -65,19 +83,45 @@ type Monitor struct {
 	threshold  *metrics.Float
 	evalWindow time.Duration
 	aggrF      aggregator
-	ticker     *time.Ticker
+	clock      clock.Clock
 	ticks      *metrics.Counter
+	metric     metrics.Observable
 
-	stopCh chan bool
+	// mu guards the datapoints, which are recorded at each tick and by Flush. Events are
+	// delivered after it is released.
+	mu         sync.Mutex
+	flushed    bool
+	stopped    bool
+	stopTicker func()
+	// done is closed when the Monitor is stopped, which abandons the delivery of an Event on
+	// a full channel. pending holds the abandoned events until Flush delivers them.
+	done    chan struct{}
+	pending []*Event
 }
 
 // NewMonitor initializes and returns a new Monitor.
 func NewMonitor(config *Config) *Monitor {
 	threshold := metrics.Float(config.AlertThreshold)
 	bufSize := int(config.Window / config.Resolution)
+
+	triggered := config.Triggered
+	if triggered == nil {
+		triggered = make(chan *Event, EventBufferSize)
+	}
+	resolved := config.Resolved
+	if resolved == nil {
+		resolved = make(chan *Event, EventBufferSize)
+	}
+
+	clk := config.Clock
+	if clk == nil {
+		clk = clock.Real{}
+	}
+
 	return &Monitor{
-		Triggered:  make(chan *Event),
-		Resolved:   make(chan *Event),
+		Name:       config.Name,
+		Triggered:  triggered,
+		Resolved:   resolved,
 		data:       make([]metrics.Observable, bufSize),
 		bufSize:    bufSize,
 		ticks:      metrics.NewCounter(),
@@ -85,67 +129,161 @@ func NewMonitor(config *Config) *Monitor {
 		threshold:  &threshold,
 		evalWindow: config.Window,
 		aggrF:      config.Aggregator,
-		stopCh:     make(chan bool, 1),
+		clock:      clk,
+		done:       make(chan struct{}),
 	}
 }
 
 // checkTrigger runs the aggregator function over the monitor's collected data.
-// If the result below the configured threshold then the Monitor notifies the time at which the
-// alert was triggered via the Triggered channel. If the Monitor was previously triggered
-// and the value is now below the threshold then the Montior notifies via the Resolved channel.
-func (m *Monitor) checkTrigger() {
-	agg := m.aggrF(m.data)
+// If the result is above the configured threshold then checkTrigger returns the Event
+// notifying the time at which the alert was triggered. If the Monitor was previously triggered
+// and the value is now below the threshold then it returns the Event resolving the alert.
+// Otherwise, it returns nil.
+func (m *Monitor) checkTrigger(data metrics.Observables) *Event {
+	agg := m.aggrF(data)
 	if !m.isTriggered && !agg.Less(m.threshold) {
 		// Alert: if we are not in a triggered state and we've hit the threshold
-		m.Triggered <- &Event{
+		m.isTriggered = true
+		return &Event{
+			Name:  m.Name,
 			Type:  EventTypeTriggered,
 			Value: agg.Float(),
-			Time:  time.Now().UTC(),
+			Time:  m.clock.Now().UTC(),
 		}
-		m.isTriggered = true
 
 	} else if m.isTriggered && agg.Less(m.threshold) {
 		// Recover: if we are in a triggered state and we are below the threshold
-		m.Resolved <- &Event{
+		m.isTriggered = false
+		return &Event{
+			Name:  m.Name,
 			Type:  EventTypeResolved,
 			Value: agg.Float(),
-			Time:  time.Now().UTC(),
+			Time:  m.clock.Now().UTC(),
 		}
-		m.isTriggered = false
+	}
+	return nil
+}
+
+// emit delivers an Event on the Triggered or Resolved channel. It must be called without
+// holding the lock, as the channels may be shared with other monitors and full. If the
+// Monitor is stopped while the channel is full, the Event is left for Flush to deliver.
+func (m *Monitor) emit(evt *Event) {
+	if evt == nil {
+		return
+	}
+
+	select {
+	case m.events(evt) <- evt:
+	case <-m.done:
+		m.mu.Lock()
+		m.pending = append(m.pending, evt)
+		m.mu.Unlock()
 	}
 }
 
+// events returns the channel on which an Event is delivered
+func (m *Monitor) events(evt *Event) chan *Event {
+	if evt.Type == EventTypeTriggered {
+		return m.Triggered
+	}
+	return m.Resolved
+}
+
 // record records the value of the metric
 func (m *Monitor) record(metric metrics.Observable) {
+	m.mu.Lock()
+	if m.flushed {
+		m.mu.Unlock()
+		return
+	}
+
+	var evt *Event
+	ticks := m.push(metric)
+	if ticks >= int64(m.bufSize) {
+		evt = m.checkTrigger(m.data)
+	}
+	m.mu.Unlock()
+
+	m.emit(evt)
+}
+
+// push appends the current value of the metric to the buffer of datapoints, resets the
+// metric and returns the number of datapoints that were recorded before it.
+func (m *Monitor) push(metric metrics.Observable) int64 {
 	ticks := m.ticks.Value()
 	// the index for inserting the next datapoint
 	insertPos := (ticks + 1) % int64(m.bufSize)
 	m.data[insertPos] = metric.Clone()
 
-	if ticks >= int64(m.bufSize) {
-		m.checkTrigger()
-	}
-
 	m.ticks.Inc(1)
 	metric.Reset()
+	return ticks
 }
 
-// Watch configures the Monitor to watch an Observable
+// Watch configures the Monitor to watch an Observable, recording its value at each tick
+// of the Monitor's clock. A stopped Monitor does not watch anything.
 func (m *Monitor) Watch(metric metrics.Observable) {
-	go func() {
-		m.ticker = time.NewTicker(1 * m.resolution)
-		for {
-			select {
-			case <-m.ticker.C:
-				m.record(metric)
-			case <-m.stopCh:
-				return
-			}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopped {
+		return
+	}
+
+	m.metric = metric
+	m.stopTicker = m.clock.Every(m.resolution, func(time.Time) {
+		m.record(metric)
+	})
+}
+
+// Flush stops the Monitor, records whatever the watched metric accumulated since the last
+// tick and evaluates the alert condition over the datapoints collected so far, even if
+// they do not yet fill the evaluation window.
+//
+// Flush is meant to be called once the input is exhausted, so that the last partial
+// interval is not lost. Any resulting Event, and any Event that could not be delivered
+// before the Monitor was stopped, is delivered on Triggered or Resolved.
+func (m *Monitor) Flush() {
+	m.Stop()
+
+	m.mu.Lock()
+	if m.metric == nil || m.flushed {
+		m.mu.Unlock()
+		return
+	}
+	m.flushed = true
+
+	m.push(m.metric)
+	data := metrics.Observables{}
+	for _, d := range m.data {
+		if d != nil {
+			data = append(data, d)
 		}
-	}()
+	}
+	events := m.pending
+	m.pending = nil
+	if evt := m.checkTrigger(data); evt != nil {
+		events = append(events, evt)
+	}
+	m.mu.Unlock()
+
+	for _, evt := range events {
+		m.events(evt) <- evt
+	}
 }
 
-// Stop stops a monitor
+// Stop stops a monitor, waiting for a tick in progress to complete. Stopping a Monitor
+// before it watches a metric prevents it from watching any.
 func (m *Monitor) Stop() {
-	m.stopCh <- true
+	m.mu.Lock()
+	stop := m.stopTicker
+	m.stopTicker = nil
+	if !m.stopped {
+		m.stopped = true
+		close(m.done)
+	}
+	m.mu.Unlock()
+
+	if stop != nil {
+		stop()
+	}
 }
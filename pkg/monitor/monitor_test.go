@@ -4,117 +4,256 @@ import (
 	"testing"
 	"time"
 
+	"github.com/perangel/dtail/pkg/clock"
 	"github.com/perangel/dtail/pkg/metrics"
 	"github.com/stretchr/testify/assert"
 )
 
-// simulateHighTraffic will simulate a high traffic volume by rapidly incrementing the counter over a given duration
-func simulateHighTraffic(counter *metrics.Counter, cancelCh <-chan int) {
-	for {
-		select {
-		case <-cancelCh:
-			return
-		default:
-			// rate: 20/s
-			counter.Inc(1)
-			time.Sleep(50 * time.Millisecond)
-		}
-	}
-}
+// start is the time at which the fake clocks used in the tests start
+var start = time.Date(2018, 5, 9, 16, 0, 0, 0, time.UTC)
 
-// simulateLowTraffic will simulate low traffic volum by slowly incrementing the counter over a given duration
-func simulateLowTraffic(counter *metrics.Counter, cancelCh <-chan int) {
-	for {
-		select {
-		case <-cancelCh:
-			return
-		default:
-			// rate: 1/s
-			counter.Inc(1)
-			time.Sleep(1 * time.Second)
-		}
+// simulateTraffic increments the counter at the given rate (hits per second) for the given number of
+// seconds, advancing the clock one second at a time so that the Monitor records every datapoint.
+func simulateTraffic(clk *clock.Virtual, counter *metrics.Counter, rate int64, seconds int) {
+	for i := 0; i < seconds; i++ {
+		counter.Inc(rate)
+		clk.Advance(1 * time.Second)
 	}
 }
 
-// TestMonitorAlerting simulates different traffic profiles by manually manipulating the counter that the
-// Monitor is watching. These tests are fairly high-level, in the sense that they depend on the execution of the Monitor
-// over time to prove the correct behavior.
-func TestMonitorAlerting(t *testing.T) {
+// newTestMonitor returns a Monitor that alerts on a mean of 5 hits/s over 5 seconds, driven by a fake clock
+func newTestMonitor() (*Monitor, *metrics.Counter, *clock.Virtual) {
+	clk := clock.NewVirtual(start)
 	monitor := NewMonitor(&Config{
 		Resolution:     1 * time.Second,
 		Window:         5 * time.Second,
 		Aggregator:     Mean,
 		AlertThreshold: 5,
+		Clock:          clk,
 	})
 
 	counter := metrics.NewCounter()
 	monitor.Watch(counter)
+	return monitor, counter, clk
+}
 
+// TestMonitorAlerting simulates different traffic profiles by manually manipulating the counter that the
+// Monitor is watching, and advancing the fake clock that drives the Monitor.
+func TestMonitorAlerting(t *testing.T) {
 	t.Run("high traffic triggers alert", func(t *testing.T) {
-		// start from a non-triggered state
-		monitor.isTriggered = false
-		cancelCh := make(chan int, 1)
-		go simulateHighTraffic(counter, cancelCh)
-		for {
-			select {
-			case <-time.After(10 * time.Second):
-				cancelCh <- 1
-				t.Fail()
-				return
-			case evt := <-monitor.Triggered:
-				cancelCh <- 1
-				assert.True(t, evt.Value > monitor.threshold.Float())
-				return
-			case <-monitor.Resolved:
-				cancelCh <- 1
-				t.Fail()
-				return
-			}
+		monitor, counter, clk := newTestMonitor()
+
+		simulateTraffic(clk, counter, 20, 5)
+		assert.Empty(t, monitor.Triggered, "the alert window is not filled yet")
+
+		simulateTraffic(clk, counter, 20, 1)
+		select {
+		case evt := <-monitor.Triggered:
+			assert.Equal(t, EventTypeTriggered, evt.Type)
+			assert.True(t, evt.Value > monitor.threshold.Float())
+			assert.Equal(t, start.Add(6*time.Second), evt.Time)
+		default:
+			t.Fatal("expected high traffic to trigger an alert")
 		}
+		assert.Empty(t, monitor.Resolved)
+	})
+
+	t.Run("alert is not triggered again while high traffic continues", func(t *testing.T) {
+		monitor, counter, clk := newTestMonitor()
+
+		simulateTraffic(clk, counter, 20, 6)
+		<-monitor.Triggered
+
+		simulateTraffic(clk, counter, 20, 10)
+		assert.Empty(t, monitor.Triggered)
+		assert.Empty(t, monitor.Resolved)
 	})
 
 	t.Run("low traffic after triggering alert resolves alert", func(t *testing.T) {
-		// start from a triggered state
-		monitor.isTriggered = true
-		cancelCh := make(chan int, 1)
-		go simulateLowTraffic(counter, cancelCh)
-		for {
-			select {
-			case <-time.After(10 * time.Second):
-				cancelCh <- 1
-				t.Fail()
-				return
-			case <-monitor.Triggered:
-				cancelCh <- 1
-				t.Fail()
-				return
-			case evt := <-monitor.Resolved:
-				cancelCh <- 1
-				assert.True(t, evt.Value < monitor.threshold.Float())
-				return
-			}
+		monitor, counter, clk := newTestMonitor()
+
+		simulateTraffic(clk, counter, 20, 6)
+		<-monitor.Triggered
+
+		simulateTraffic(clk, counter, 1, 10)
+		select {
+		case evt := <-monitor.Resolved:
+			assert.Equal(t, EventTypeResolved, evt.Type)
+			assert.True(t, evt.Value < monitor.threshold.Float())
+		default:
+			t.Fatal("expected low traffic to resolve the alert")
 		}
+		assert.Empty(t, monitor.Triggered)
 	})
 
 	t.Run("low traffic does not trigger an alert", func(t *testing.T) {
-		// start from a non-triggered state
-		monitor.isTriggered = false
-		cancelCh := make(chan int, 1)
-		go simulateLowTraffic(counter, cancelCh)
-		for {
-			select {
-			case <-time.After(5 * time.Second):
-				cancelCh <- 1
-				return
-			case <-monitor.Triggered:
-				cancelCh <- 1
-				t.Fail()
-				return
-			case <-monitor.Resolved:
-				cancelCh <- 1
-				t.Fail()
-				return
+		monitor, counter, clk := newTestMonitor()
+
+		simulateTraffic(clk, counter, 1, 60)
+		assert.Empty(t, monitor.Triggered)
+		assert.Empty(t, monitor.Resolved)
+	})
+
+	t.Run("stopped monitor does not record", func(t *testing.T) {
+		monitor, counter, clk := newTestMonitor()
+
+		monitor.Stop()
+		simulateTraffic(clk, counter, 20, 10)
+		assert.Empty(t, monitor.Triggered)
+		assert.Equal(t, int64(0), monitor.ticks.Value())
+	})
+
+	t.Run("monitor stopped before watching does not record", func(t *testing.T) {
+		clk := clock.NewVirtual(start)
+		monitor := NewMonitor(&Config{
+			Resolution:     1 * time.Second,
+			Window:         5 * time.Second,
+			Aggregator:     Mean,
+			AlertThreshold: 5,
+			Clock:          clk,
+		})
+
+		monitor.Stop()
+		counter := metrics.NewCounter()
+		monitor.Watch(counter)
+		simulateTraffic(clk, counter, 20, 10)
+		assert.Empty(t, monitor.Triggered)
+		assert.Equal(t, int64(0), monitor.ticks.Value())
+	})
+
+	t.Run("undelivered event does not block the monitor", func(t *testing.T) {
+		monitor := NewMonitor(&Config{
+			Resolution:     1 * time.Millisecond,
+			Window:         1 * time.Millisecond,
+			Aggregator:     Mean,
+			AlertThreshold: 0,
+			// nobody receives the events
+			Triggered: make(chan *Event),
+		})
+		monitor.Watch(metrics.NewCounter())
+
+		// leave time for a tick to block on the delivery of the triggered event
+		time.Sleep(20 * time.Millisecond)
+
+		stopped := make(chan struct{})
+		go func() {
+			monitor.Stop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+			t.Fatal("timed out stopping a monitor blocked on the delivery of an event")
+		}
+	})
+}
+
+func TestMonitorHistogram(t *testing.T) {
+	t.Run("alert on a quantile over the window", func(t *testing.T) {
+		clk := clock.NewVirtual(start)
+		monitor := NewMonitor(&Config{
+			Resolution:     1 * time.Second,
+			Window:         5 * time.Second,
+			Aggregator:     Sum,
+			AlertThreshold: 100,
+			Clock:          clk,
+		})
+
+		latency := metrics.NewHistogramWithQuantile(0.99)
+		monitor.Watch(latency)
+		for i := 0; i < 6; i++ {
+			for v := int64(1); v <= 99; v++ {
+				latency.Observe(v)
 			}
+			// a single slow request per second is over the 99th percentile
+			latency.Observe(1000)
+			clk.Advance(1 * time.Second)
+		}
+		assert.Empty(t, monitor.Triggered, "the 99th percentile is below the threshold")
+
+		for i := 0; i < 5; i++ {
+			for v := int64(1); v <= 90; v++ {
+				latency.Observe(v)
+			}
+			for v := int64(0); v < 10; v++ {
+				latency.Observe(1000)
+			}
+			clk.Advance(1 * time.Second)
+		}
+		select {
+		case evt := <-monitor.Triggered:
+			assert.Equal(t, 1000.0, evt.Value)
+		default:
+			t.Fatal("expected slow requests to trigger an alert")
+		}
+	})
+}
+
+func TestMonitorFlush(t *testing.T) {
+	t.Run("flush evaluates a partially filled window", func(t *testing.T) {
+		monitor := NewMonitor(&Config{
+			Resolution:     1 * time.Minute,
+			Window:         5 * time.Minute,
+			Aggregator:     Mean,
+			AlertThreshold: 5,
+			Clock:          clock.NewVirtual(start),
+		})
+
+		counter := metrics.NewCounter()
+		monitor.Watch(counter)
+		for i := 0; i < 10; i++ {
+			counter.Inc(1)
+		}
+		monitor.Flush()
+
+		select {
+		case evt := <-monitor.Triggered:
+			assert.Equal(t, 10.0, evt.Value)
+		default:
+			t.Fatal("expected flush to trigger an alert")
+		}
+	})
+
+	t.Run("flush delivers the event abandoned by stop", func(t *testing.T) {
+		triggered := make(chan *Event, 1)
+		monitor := NewMonitor(&Config{
+			Resolution:     1 * time.Millisecond,
+			Window:         1 * time.Millisecond,
+			Aggregator:     Mean,
+			AlertThreshold: 0,
+			Triggered:      triggered,
+		})
+		// fill the channel, so that the triggered event cannot be delivered
+		triggered <- &Event{}
+		monitor.Watch(metrics.NewCounter())
+		time.Sleep(20 * time.Millisecond)
+
+		monitor.Stop()
+		<-triggered
+		monitor.Flush()
+		select {
+		case evt := <-triggered:
+			assert.Equal(t, EventTypeTriggered, evt.Type)
+		default:
+			t.Fatal("expected flush to deliver the triggered event")
 		}
 	})
+
+	t.Run("flush without data does not alert", func(t *testing.T) {
+		monitor := NewMonitor(&Config{
+			Resolution:     1 * time.Minute,
+			Window:         5 * time.Minute,
+			Aggregator:     Mean,
+			AlertThreshold: 5,
+			Clock:          clock.NewVirtual(start),
+		})
+
+		monitor.Watch(metrics.NewCounter())
+		monitor.Flush()
+		// flushing twice is a no-op
+		monitor.Flush()
+
+		assert.Empty(t, monitor.Triggered)
+		assert.Empty(t, monitor.Resolved)
+	})
 }
@@ -0,0 +1,224 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// apacheFormats are the log formats commonly defined, by nickname, in the Apache configuration
+var apacheFormats = map[string]string{
+	"common":       `%h %l %u %t "%r" %>s %b`,
+	"combined":     `%h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-agent}i"`,
+	"combinedio":   `%h %l %u %t "%r" %>s %b "%{Referer}i" "%{User-agent}i" %I %O`,
+	"vhost_common": `%v %h %l %u %t "%r" %>s %b`,
+	"referer":      `%{Referer}i -> %U`,
+	"agent":        `%{User-agent}i`,
+}
+
+// apacheDirectives maps the Apache format directives without parameters onto Record fields
+var apacheDirectives = map[byte]formatToken{
+	'a': {field: "remote_host"},
+	'A': {field: "local_ip"},
+	'B': {field: "response_size"},
+	'b': {field: "response_size"},
+	'D': {field: "duration_us", fieldType: IntField},
+	'f': {field: "filename"},
+	'h': {field: "remote_host"},
+	'H': {field: "http_version"},
+	'I': {field: "bytes_received", fieldType: IntField},
+	'k': {field: "keepalive_requests", fieldType: IntField},
+	'l': {field: "rfc931"},
+	'L': {field: "log_id"},
+	'm': {field: "request_method"},
+	'O': {field: "bytes_sent", fieldType: IntField},
+	'p': {field: "server_port", fieldType: IntField},
+	'P': {field: "pid", fieldType: IntField},
+	'q': {field: "query_string"},
+	'r': {field: "request"},
+	'R': {field: "handler"},
+	's': {field: "status_code"},
+	'S': {field: "bytes_transferred", fieldType: IntField},
+	't': {field: "time_local", timeLayout: datetimeLayout},
+	'T': {field: "duration_s", fieldType: IntField},
+	'u': {field: "user"},
+	'U': {field: "request_uri"},
+	'v': {field: "server_name"},
+	'V': {field: "server_name"},
+	'X': {field: "connection_status"},
+}
+
+// strftimeLayouts maps strftime conversions onto their time.Parse() layout
+var strftimeLayouts = map[byte]string{
+	'a': "Mon", 'A': "Monday", 'b': "Jan", 'B': "January", 'd': "02", 'e': "_2",
+	'F': "2006-01-02", 'H': "15", 'I': "03", 'm': "01", 'M': "04", 'p': "PM",
+	'S': "05", 'T': "15:04:05", 'y': "06", 'Y': "2006", 'z': "-0700", 'Z': "MST",
+	'%': "%",
+}
+
+func init() {
+	Register("apache", func(options Options) (Parser, error) {
+		format := options["log_format"]
+		if format == "" {
+			name := options["name"]
+			if name == "" {
+				name = "combined"
+			}
+
+			var ok bool
+			if format, ok = apacheFormats[name]; !ok {
+				return nil, fmt.Errorf("unknown log format nickname %q, set the log_format option", name)
+			}
+		}
+
+		return NewApacheParser(format)
+	})
+}
+
+// NewApacheParser compiles an Apache `LogFormat` string (e.g. `%h %l %u %t \"%r\" %>s %b %D`) into
+// a Parser. Quotes may be escaped with a backslash, as in the Apache configuration. Directives
+// describing the request populate the Request, the others populate Fields. Header, cookie and
+// environment directives are named after the variable, following nginx: `%{X-Forwarded-For}i`
+// populates `http_x_forwarded_for`, `%{Location}o` populates `sent_http_location`,
+// `%{session}C` populates `cookie_session` and `%{HOME}e` populates `env_home`.
+func NewApacheParser(format string) (*RegexParser, error) {
+	format = strings.NewReplacer(`\"`, `"`, `\\`, `\`, `\n`, "\n", `\t`, "\t").Replace(format)
+
+	tokens := []formatToken{}
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			tokens = appendToken(tokens, formatToken{literal: format[i : i+1]})
+			continue
+		}
+
+		token, end, err := apacheDirective(format, i)
+		if err != nil {
+			return nil, err
+		}
+		i = end
+
+		if token.field == "time_local" {
+			// %t is written in brackets, e.g. [10/Oct/2000:13:55:36 -0700]
+			tokens = appendToken(tokens, formatToken{literal: "["})
+			tokens = appendToken(tokens, token)
+			tokens = appendToken(tokens, formatToken{literal: "]"})
+			continue
+		}
+		tokens = appendToken(tokens, token)
+	}
+
+	p, err := compileFormat(tokens)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile log format %q: %s", format, err)
+	}
+	return p, nil
+}
+
+// apacheDirective reads the directive starting with the `%` at format[start]. It returns the
+// token for the directive and the index of its last character.
+func apacheDirective(format string, start int) (formatToken, int, error) {
+	i := start + 1
+
+	// skip the modifiers: status code conditions (e.g. `%!200,304{Referer}i`) and the
+	// original/final request selectors (e.g. `%>s`)
+	for i < len(format) && strings.IndexByte("!0123456789,<>", format[i]) >= 0 {
+		i++
+	}
+
+	param := ""
+	if i < len(format) && format[i] == '{' {
+		end := strings.IndexByte(format[i:], '}')
+		if end < 0 {
+			return formatToken{}, 0, fmt.Errorf("unterminated directive %q", format[start:])
+		}
+		param = format[i+1 : i+end]
+		i += end + 1
+	}
+	if i >= len(format) {
+		return formatToken{}, 0, fmt.Errorf("incomplete directive %q", format[start:])
+	}
+
+	directive := format[i]
+	switch directive {
+	case '%':
+		return formatToken{literal: "%"}, i, nil
+
+	case 'i':
+		switch strings.ToLower(param) {
+		case "referer":
+			return formatToken{field: "referer"}, i, nil
+		case "user-agent":
+			return formatToken{field: "user_agent"}, i, nil
+		}
+		return variableToken("http_", param), i, nil
+	case 'o':
+		return variableToken("sent_http_", param), i, nil
+	case 'C':
+		return variableToken("cookie_", param), i, nil
+	case 'e':
+		return variableToken("env_", param), i, nil
+	case 'n':
+		return variableToken("note_", param), i, nil
+	}
+
+	if param != "" {
+		switch directive {
+		case 'a':
+			return formatToken{field: "peer_ip"}, i, nil
+		case 'p':
+			return formatToken{field: headerField("", param) + "_port", fieldType: IntField}, i, nil
+		case 'T':
+			return formatToken{field: headerField("duration_", param), fieldType: IntField}, i, nil
+		case 't':
+			return apacheTimeDirective(param), i, nil
+		}
+	}
+
+	token, ok := apacheDirectives[directive]
+	if !ok {
+		return formatToken{}, 0, fmt.Errorf("unsupported directive %q", format[start:i+1])
+	}
+	return token, i, nil
+}
+
+// variableToken returns the token for a header, cookie, environment variable or note directive,
+// named after the variable with the given prefix. Apache logs `-` for a missing variable, which
+// is not kept in Fields.
+func variableToken(prefix, variable string) formatToken {
+	return formatToken{field: headerField(prefix, variable), fieldType: StringField}
+}
+
+// apacheEpochLayouts are the numeric timestamp layouts of the `%{format}t` directives logging
+// the time since the epoch
+var apacheEpochLayouts = map[string]string{
+	"sec":  unixLayout,
+	"msec": unixMilliLayout,
+	"usec": unixMicroLayout,
+}
+
+// apacheTimeDirective returns the token for a `%{format}t` directive, where format is either
+// a strftime format, or one of sec, msec, usec, msec_frac or usec_frac, optionally prefixed
+// with begin: or end:. The time since the epoch (sec, msec or usec) sets the timestamp unless
+// another time directive precedes it.
+func apacheTimeDirective(param string) formatToken {
+	param = strings.TrimPrefix(strings.TrimPrefix(param, "begin:"), "end:")
+
+	switch param {
+	case "sec", "msec", "usec":
+		return formatToken{field: "time_" + param, fieldType: IntField, timeLayout: apacheEpochLayouts[param]}
+	case "msec_frac", "usec_frac":
+		return formatToken{field: "time_" + param, fieldType: IntField}
+	}
+
+	var layout strings.Builder
+	for i := 0; i < len(param); i++ {
+		if param[i] == '%' && i+1 < len(param) {
+			if l, ok := strftimeLayouts[param[i+1]]; ok {
+				layout.WriteString(l)
+				i++
+				continue
+			}
+		}
+		layout.WriteByte(param[i])
+	}
+	return formatToken{field: "time", timeLayout: layout.String()}
+}
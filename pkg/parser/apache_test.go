@@ -0,0 +1,127 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApacheParser(t *testing.T) {
+	t.Run("default combined format", func(t *testing.T) {
+		p, err := New("apache", nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		r, err := p.ParseLine(`127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08 [en] (Win98; I ;Nav)"`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "127.0.0.1", r.RemoteHost)
+		assert.Equal(t, "frank", r.AuthUser)
+		assert.Equal(t, 2000, r.Timestamp.Year())
+		assert.Equal(t, "/apache_pb.gif", r.URI)
+		assert.Equal(t, 200, r.StatusCode)
+		assert.Equal(t, 2326, r.ResponseSizeBytes)
+		assert.Equal(t, "http://www.example.com/start.html", r.Referer)
+		assert.Equal(t, "Mozilla/4.08 [en] (Win98; I ;Nav)", r.UserAgent)
+	})
+
+	t.Run("predefined nicknames", func(t *testing.T) {
+		p, err := New("apache", Options{"name": "vhost_common"})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		r, err := p.ParseLine(`www.example.com 127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "GET / HTTP/1.0" 304 -`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "www.example.com", r.Fields["server_name"])
+		assert.Equal(t, 304, r.StatusCode)
+
+		_, err = New("apache", Options{"name": "unknown"})
+		assert.Error(t, err)
+	})
+
+	t.Run("custom format with headers, cookies and timings", func(t *testing.T) {
+		p, err := NewApacheParser(`%a %{X-Forwarded-For}i %t \"%r\" %>s %B %D %{ms}T \"%{session}C\" %{Content-Type}o %400,501{User-agent}i %%`)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		r, err := p.ParseLine(`10.0.0.1 203.0.113.7 [10/Oct/2000:13:55:36 -0700] "POST /login HTTP/1.1" 302 0 1532 1 "a b c" text/html - %`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "10.0.0.1", r.RemoteHost)
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, 302, r.StatusCode)
+		assert.Equal(t, "-", r.UserAgent)
+		assert.Equal(t, map[string]interface{}{
+			"http_x_forwarded_for":   "203.0.113.7",
+			"duration_us":            int64(1532),
+			"duration_ms":            int64(1),
+			"cookie_session":         "a b c",
+			"sent_http_content_type": "text/html",
+		}, r.Fields)
+		assert.Equal(t, duration(1532*time.Microsecond), r.Duration)
+	})
+
+	t.Run("strftime time format", func(t *testing.T) {
+		p, err := NewApacheParser(`[%{%Y-%m-%d %T %z}t] %h %{msec}t`)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		r, err := p.ParseLine(`[2000-10-10 13:55:36 -0700] 127.0.0.1 971211336000`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, time.Date(2000, 10, 10, 20, 55, 36, 0, time.UTC), r.Timestamp.UTC())
+		assert.Equal(t, int64(971211336000), r.Fields["time_msec"])
+	})
+
+	t.Run("epoch time format sets the timestamp", func(t *testing.T) {
+		for format, value := range map[string]string{
+			"sec":  "971211336",
+			"msec": "971211336123",
+			"usec": "971211336123456",
+		} {
+			p, err := NewApacheParser(`%h %{` + format + `}t`)
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			r, err := p.ParseLine(`127.0.0.1 ` + value)
+			if !assert.NoError(t, err, format) {
+				return
+			}
+			assert.Equal(t, int64(971211336), r.Timestamp.Unix(), format)
+		}
+	})
+
+	t.Run("missing headers are not recorded", func(t *testing.T) {
+		p, err := NewApacheParser(`%h "%{X-Forwarded-For}i" "%{Location}o" "%{session}C"`)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		r, err := p.ParseLine(`127.0.0.1 "-" "-" "abc"`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.NotContains(t, r.Fields, "http_x_forwarded_for")
+		assert.NotContains(t, r.Fields, "sent_http_location")
+		assert.Equal(t, "abc", r.Fields["cookie_session"])
+	})
+
+	t.Run("unsupported directive returns error", func(t *testing.T) {
+		_, err := NewApacheParser(`%h %J`)
+		assert.Error(t, err)
+
+		_, err = NewApacheParser(`%h %{Referer`)
+		assert.Error(t, err)
+	})
+}
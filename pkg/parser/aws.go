@@ -0,0 +1,122 @@
+package parser
+
+import (
+	"time"
+)
+
+// albColumns are the columns of Application Load Balancer access logs, see
+// https://docs.aws.amazon.com/elasticloadbalancing/latest/application/load-balancer-access-logs.html
+var albColumns = []column{
+	{field: "type"},
+	{field: "datetime"},
+	{field: "elb"},
+	{field: "remote_host", portField: "remote_port"},
+	{field: "target", portField: "target_port"},
+	{field: "request_processing_time", fieldType: FloatField},
+	{field: "target_processing_time", fieldType: FloatField},
+	{field: "response_processing_time", fieldType: FloatField},
+	{field: "status_code"},
+	{field: "target_status_code", fieldType: IntField},
+	{field: "received_bytes", fieldType: IntField},
+	{field: "response_size"},
+	{field: "request"},
+	{field: "user_agent"},
+	{field: "ssl_cipher"},
+	{field: "ssl_protocol"},
+	{field: "target_group_arn"},
+	{field: "trace_id"},
+	{field: "domain_name"},
+	{field: "chosen_cert_arn"},
+	{field: "matched_rule_priority", fieldType: IntField},
+	{field: "request_creation_time"},
+	{field: "actions_executed"},
+	{field: "redirect_url"},
+	{field: "error_reason"},
+	{field: "target_list"},
+	{field: "target_status_code_list"},
+	{field: "classification"},
+	{field: "classification_reason"},
+	{field: "conn_trace_id"},
+}
+
+// elbColumns are the columns of Classic Load Balancer access logs, see
+// https://docs.aws.amazon.com/elasticloadbalancing/latest/classic/access-log-collection.html
+var elbColumns = []column{
+	{field: "datetime"},
+	{field: "elb"},
+	{field: "remote_host", portField: "remote_port"},
+	{field: "backend", portField: "backend_port"},
+	{field: "request_processing_time", fieldType: FloatField},
+	{field: "backend_processing_time", fieldType: FloatField},
+	{field: "response_processing_time", fieldType: FloatField},
+	{field: "status_code"},
+	{field: "backend_status_code", fieldType: IntField},
+	{field: "received_bytes", fieldType: IntField},
+	{field: "response_size"},
+	{field: "request"},
+	{field: "user_agent"},
+	{field: "ssl_cipher"},
+	{field: "ssl_protocol"},
+}
+
+// cloudFrontFields are the W3C fields of CloudFront standard logs, in the order they are
+// written, see https://docs.aws.amazon.com/AmazonCloudFront/latest/DeveloperGuide/AccessLogs.html
+var cloudFrontFields = []string{
+	"date", "time", "x-edge-location", "sc-bytes", "c-ip", "cs-method", "cs(Host)",
+	"cs-uri-stem", "sc-status", "cs(Referer)", "cs(User-Agent)", "cs-uri-query", "cs(Cookie)",
+	"x-edge-result-type", "x-edge-request-id", "x-host-header", "cs-protocol", "cs-bytes",
+	"time-taken", "x-forwarded-for", "ssl-protocol", "ssl-cipher", "x-edge-response-result-type",
+	"cs-protocol-version", "fle-status", "fle-encrypted-fields", "c-port", "time-to-first-byte",
+	"x-edge-detailed-result-type", "sc-content-type", "sc-content-len", "sc-range-start",
+	"sc-range-end",
+}
+
+func init() {
+	Register("alb", func(Options) (Parser, error) {
+		return NewALBParser(), nil
+	})
+	Register("elb", func(Options) (Parser, error) {
+		return NewELBParser(), nil
+	})
+	Register("cloudfront", func(Options) (Parser, error) {
+		return NewCloudFrontParser(), nil
+	})
+}
+
+// NewALBParser returns a new Parser for AWS Application Load Balancer access logs. The request
+// line, client address, status code and sent bytes populate the Request, the other columns
+// (e.g. `target_processing_time` or `trace_id`) populate Fields. The target processing time sets
+// the Duration of the Request.
+func NewALBParser() *ColumnParser {
+	return &ColumnParser{
+		columns:    albColumns,
+		required:   13, // up to the request line
+		split:      splitQuoted,
+		timeLayout: time.RFC3339Nano,
+	}
+}
+
+// NewELBParser returns a new Parser for AWS Classic Load Balancer access logs. The request line,
+// client address, status code and sent bytes populate the Request, the other columns (e.g.
+// `backend_processing_time`) populate Fields. The backend processing time sets the Duration of the
+// Request.
+func NewELBParser() *ColumnParser {
+	return &ColumnParser{
+		columns:    elbColumns,
+		required:   12, // up to the request line
+		split:      splitQuoted,
+		timeLayout: time.RFC3339Nano,
+	}
+}
+
+// NewCloudFrontParser returns a new Parser for AWS CloudFront standard logs, which are tab-separated
+// W3C extended logs (see W3CParser) with URL-encoded values. The columns are those currently
+// written by CloudFront, until a `#Fields` directive is read. `#Fields` directives that do not
+// declare x-edge-location are rejected, as they do not describe CloudFront logs. The time-taken
+// field is in seconds.
+func NewCloudFrontParser() *W3CParser {
+	p := &W3CParser{unescape: true, requiredField: "x-edge-location", timeTakenUnit: time.Second}
+	p.setFields(cloudFrontFields)
+	p.columns.required = 11 // up to the user agent, older logs have fewer columns
+	return p
+}
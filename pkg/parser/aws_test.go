@@ -0,0 +1,182 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestALBParser(t *testing.T) {
+	p, err := New("alb", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	t.Run("parse alb log line", func(t *testing.T) {
+		line := `https 2018-07-02T22:23:00.186641Z app/my-loadbalancer/50dc6c495c0c9188 192.168.131.39:2817 10.0.0.1:80 0.086 0.048 0.037 200 201 0 57 "GET https://www.example.com:443/api/users?id=1 HTTP/1.1" "curl/7.46.0" ECDHE-RSA-AES128-GCM-SHA256 TLSv1.2 arn:aws:elasticloadbalancing:us-east-2:123456789012:targetgroup/my-targets/73e2d6bc24d8a067 "Root=1-58337281-1d84f3d73c47ec4e58577259" "www.example.com" "arn:aws:acm:us-east-2:123456789012:certificate/12345678-1234-1234-1234-123456789012" 1 2018-07-02T22:22:48.364000Z "authenticate,forward" "-" "-" "10.0.0.1:80" "201" "-" "-"`
+
+		r, err := p.ParseLine(line)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, time.Date(2018, 7, 2, 22, 23, 0, 186641000, time.UTC), *r.Timestamp)
+		assert.Equal(t, "192.168.131.39", r.RemoteHost)
+		assert.Equal(t, "GET", r.Method)
+		assert.Equal(t, "https://www.example.com:443/api/users?id=1", r.URI)
+		assert.Equal(t, "/api", r.Section())
+		assert.Equal(t, "1.1", r.HTTPVersion)
+		assert.Equal(t, 200, r.StatusCode)
+		assert.Equal(t, 57, r.ResponseSizeBytes)
+		assert.Equal(t, "curl/7.46.0", r.UserAgent)
+
+		assert.Equal(t, int64(2817), r.Fields["remote_port"])
+		assert.Equal(t, "10.0.0.1", r.Fields["target"])
+		assert.Equal(t, int64(80), r.Fields["target_port"])
+		assert.Equal(t, 0.048, r.Fields["target_processing_time"])
+		assert.Equal(t, duration(48*time.Millisecond), r.Duration)
+		assert.Equal(t, int64(201), r.Fields["target_status_code"])
+		assert.Equal(t, "Root=1-58337281-1d84f3d73c47ec4e58577259", r.Fields["trace_id"])
+		assert.Equal(t, "authenticate,forward", r.Fields["actions_executed"])
+		assert.NotContains(t, r.Fields, "redirect_url")
+	})
+
+	t.Run("parse alb log line without target", func(t *testing.T) {
+		line := `http 2018-11-30T22:23:00.186641Z app/my-loadbalancer/50dc6c495c0c9188 192.168.131.39:2817 - -1 -1 -1 503 - 34 366 "GET http://www.example.com:80/ HTTP/1.1" "curl/7.46.0" - -`
+
+		r, err := p.ParseLine(line)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, 503, r.StatusCode)
+		assert.Equal(t, "/", r.Section())
+		assert.Equal(t, -1.0, r.Fields["target_processing_time"])
+		assert.Nil(t, r.Duration)
+		assert.NotContains(t, r.Fields, "target")
+		assert.NotContains(t, r.Fields, "target_status_code")
+	})
+
+	t.Run("parse truncated line returns error", func(t *testing.T) {
+		_, err := p.ParseLine(`http 2018-11-30T22:23:00.186641Z app/my-loadbalancer/50dc6c495c0c9188 192.168.131.39:2817`)
+		assert.Error(t, err)
+	})
+
+	t.Run("parse classic elb line returns error", func(t *testing.T) {
+		_, err := p.ParseLine(`2015-05-13T23:39:43.945958Z my-loadbalancer 192.168.131.39:2817 10.0.0.1:80 0.000073 0.001048 0.000057 200 200 0 29 "GET http://www.example.com:80/ HTTP/1.1" "curl/7.38.0" - -`)
+		assert.Error(t, err)
+	})
+}
+
+func TestELBParser(t *testing.T) {
+	p, err := New("elb", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	t.Run("parse elb log line", func(t *testing.T) {
+		line := `2015-05-13T23:39:43.945958Z my-loadbalancer 192.168.131.39:2817 10.0.0.1:80 0.000073 0.001048 0.000057 404 404 0 29 "GET http://www.example.com:80/images/logo.png HTTP/1.1" "curl/7.38.0" - -`
+
+		r, err := p.ParseLine(line)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, time.Date(2015, 5, 13, 23, 39, 43, 945958000, time.UTC), *r.Timestamp)
+		assert.Equal(t, "192.168.131.39", r.RemoteHost)
+		assert.Equal(t, "/images", r.Section())
+		assert.Equal(t, 404, r.StatusCode)
+		assert.Equal(t, 29, r.ResponseSizeBytes)
+		assert.Equal(t, "curl/7.38.0", r.UserAgent)
+		assert.Equal(t, "10.0.0.1", r.Fields["backend"])
+		assert.Equal(t, 0.001048, r.Fields["backend_processing_time"])
+		assert.Equal(t, duration(1048*time.Microsecond), r.Duration)
+		assert.NotContains(t, r.Fields, "ssl_cipher")
+	})
+
+	t.Run("parse tcp listener line", func(t *testing.T) {
+		line := `2015-05-13T23:39:43.945958Z my-loadbalancer 192.168.131.39:2817 10.0.0.1:80 0.001069 0.000028 0.000041 - - 82 305 "- - - " "-" - -`
+
+		r, err := p.ParseLine(line)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, 0, r.StatusCode)
+		assert.Equal(t, 305, r.ResponseSizeBytes)
+	})
+}
+
+func TestCloudFrontParser(t *testing.T) {
+	p, err := New("cloudfront", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	t.Run("skip directives", func(t *testing.T) {
+		_, err := p.ParseLine("#Version: 1.0")
+		assert.Equal(t, ErrSkipLine, err)
+	})
+
+	t.Run("parse cloudfront log line", func(t *testing.T) {
+		line := "2019-12-04\t21:02:31\tLAX1\t392\t192.0.2.100\tGET\td111111abcdef8.cloudfront.net\t/index.html\t200\t-\tMozilla/5.0%20(Windows%20NT%2010.0)\t-\t-\tHit\tSOX4xwn4XV6Q4rgb7XiVGOHms_BGlTAC4KyHmureZmBNrjGdRLiNIQ==\td111111abcdef8.cloudfront.net\thttps\t23\t0.001\t-\tTLSv1.2\tECDHE-RSA-AES128-GCM-SHA256\tHit\tHTTP/2.0\t-\t-\t11040\t0.001\tHit\ttext/html\t78\t-\t-"
+
+		r, err := p.ParseLine(line)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, time.Date(2019, 12, 4, 21, 2, 31, 0, time.UTC), *r.Timestamp)
+		assert.Equal(t, "192.0.2.100", r.RemoteHost)
+		assert.Equal(t, "GET", r.Method)
+		assert.Equal(t, "/index.html", r.URI)
+		assert.Equal(t, "2.0", r.HTTPVersion)
+		assert.Equal(t, 200, r.StatusCode)
+		assert.Equal(t, 392, r.ResponseSizeBytes)
+		assert.Equal(t, "Mozilla/5.0 (Windows NT 10.0)", r.UserAgent)
+
+		assert.Equal(t, "LAX1", r.Fields["x_edge_location"])
+		assert.Equal(t, "d111111abcdef8.cloudfront.net", r.Fields["cs_host"])
+		assert.Equal(t, 0.001, r.Fields["time_taken"])
+		assert.Equal(t, duration(time.Millisecond), r.Duration)
+		assert.Equal(t, int64(11040), r.Fields["c_port"])
+		assert.Equal(t, int64(78), r.Fields["sc_content_len"])
+		assert.NotContains(t, r.Fields, "cs_uri_query")
+	})
+
+	t.Run("parse legacy cloudfront log line", func(t *testing.T) {
+		line := "2014-05-23\t01:13:11\tFRA2\t182\t192.0.2.10\tGET\td111111abcdef8.cloudfront.net\t/view/my/file.html\t200\twww.displaymyfiles.com\tMozilla/4.0%20(compatible;%20MSIE%205.0b1;%20Mac_PowerPC)\t-\tzip=98101\tRefreshHit\tMRVMF7KydIvxMWfJIglgwHQwZsbG2IhRJ07sn9AkKUFSHS9EXAMPLE==\td111111abcdef8.cloudfront.net\thttp\t-\t0.001\t-\t-\t-\tRefreshHit\tHTTP/1.1"
+
+		r, err := p.ParseLine(line)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "/view", r.Section())
+		assert.Equal(t, "www.displaymyfiles.com", r.Referer)
+		assert.Equal(t, "RefreshHit", r.Fields["x_edge_result_type"])
+	})
+
+	t.Run("parse columns declared by fields directive", func(t *testing.T) {
+		p := NewCloudFrontParser()
+		_, err := p.ParseLine("#Fields: date time x-edge-location c-ip cs-method cs-uri-stem sc-status cs(User-Agent)")
+		assert.Equal(t, ErrSkipLine, err)
+
+		r, err := p.ParseLine("2019-12-04\t21:02:31\tLAX1\t192.0.2.100\tGET\t/index.html\t304\tcurl/7.58.0")
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "/index.html", r.URI)
+		assert.Equal(t, 304, r.StatusCode)
+		assert.Equal(t, "curl/7.58.0", r.UserAgent)
+	})
+
+	t.Run("parse fields directive of other w3c logs returns error", func(t *testing.T) {
+		p := NewCloudFrontParser()
+		_, err := p.ParseLine("#Fields: date time c-ip cs-method cs-uri-stem sc-status")
+		assert.Error(t, err)
+
+		_, err = p.ParseLine("2019-12-04 21:02:31 192.0.2.100 GET /index.html 304")
+		assert.Error(t, err)
+	})
+
+	t.Run("parse space-separated line returns error", func(t *testing.T) {
+		_, err := p.ParseLine(`127.0.0.1 - - [09/May/2018:16:00:39 +0000] "GET / HTTP/1.0" 200 1`)
+		assert.Error(t, err)
+	})
+}
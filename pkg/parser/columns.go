@@ -0,0 +1,160 @@
+package parser
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// column describes a column of a delimited log format
+type column struct {
+	// field is the name of the Record field (see SetField) set by the column. The values of the
+	// `datetime` columns are joined with a space, e.g. separate date and time columns.
+	field string
+	// fieldType is the type of an extra field
+	fieldType FieldType
+	// portField is set if the column holds a `host:port` address, the host setting field and
+	// the port setting portField
+	portField string
+}
+
+// ColumnParser is a Parser for log formats with a fixed sequence of columns (e.g. AWS load
+// balancer logs). Columns describing the request populate the Request, the others populate
+// Fields. Additional trailing columns are ignored, as formats may add columns over time.
+type ColumnParser struct {
+	columns []column
+	// required is the number of columns a line must have at least
+	required int
+	// split splits a line into the values of its columns
+	split func(line string) ([]string, error)
+	// timeLayout is the time.Parse() layout of the datetime columns
+	timeLayout string
+	// unescape is true if the values are URL-encoded
+	unescape bool
+}
+
+// ParseLine parses a line of columns. Lines starting with `#` are directives, and return
+// ErrSkipLine.
+func (p *ColumnParser) ParseLine(line string) (*Record, error) {
+	if strings.HasPrefix(line, "#") {
+		return nil, ErrSkipLine
+	}
+
+	values, err := p.split(line)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse log line: %s", err)
+	}
+	if len(values) < p.required {
+		return nil, fmt.Errorf("failed to parse log line: expected %d columns, got %d", p.required, len(values))
+	}
+	if len(values) > len(p.columns) {
+		values = values[:len(p.columns)]
+	}
+
+	r := NewRecord()
+	datetime := []string{}
+	for i, value := range values {
+		c := p.columns[i]
+		if p.unescape {
+			if v, err := url.PathUnescape(value); err == nil {
+				value = v
+			}
+		}
+
+		switch {
+		case c.field == "datetime":
+			datetime = append(datetime, value)
+		case c.portField != "":
+			if host, port, err := net.SplitHostPort(value); err == nil {
+				value = host
+				r.SetTypedField(c.portField, port, IntField)
+			}
+			if err := p.setField(r, c, value); err != nil {
+				return nil, err
+			}
+		default:
+			if err := p.setField(r, c, value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(datetime) > 0 {
+		ts, err := time.Parse(p.timeLayout, strings.Join(datetime, " "))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse request timestamp: %s", err)
+		}
+		r.Timestamp = &ts
+	}
+	r.setDuration(0)
+	return r, nil
+}
+
+// setField sets the field of a column. Missing values of extra fields are not set.
+func (p *ColumnParser) setField(r *Record, c column, value string) error {
+	if isRequestField(c.field) {
+		return r.SetField(c.field, value)
+	}
+
+	t := c.fieldType
+	if t == "" {
+		t = StringField
+	}
+	r.SetTypedField(c.field, value, t)
+	return nil
+}
+
+// isRequestField returns true if SetField sets the named field of the Request
+func isRequestField(name string) bool {
+	switch name {
+	case "remote_host", "rfc931", "user", "request_method", "request_uri", "http_version",
+		"request", "referer", "user_agent", "status_code", "response_size":
+		return true
+	}
+	return false
+}
+
+// splitQuoted splits a line into space-separated values, which may be quoted (e.g. `"GET /
+// HTTP/1.1"`), with quotes escaped with a backslash. Quotes are removed, escapes are kept.
+func splitQuoted(line string) ([]string, error) {
+	values := []string{}
+	for i := 0; i < len(line); {
+		if line[i] == ' ' {
+			i++
+			continue
+		}
+
+		if line[i] != '"' {
+			end := strings.IndexByte(line[i:], ' ')
+			if end < 0 {
+				end = len(line) - i
+			}
+			values = append(values, line[i:i+end])
+			i += end
+			continue
+		}
+
+		end := -1
+		for j := i + 1; j < len(line); j++ {
+			if line[j] == '\\' {
+				j++
+			} else if line[j] == '"' {
+				end = j
+				break
+			}
+		}
+		if end < 0 {
+			return nil, fmt.Errorf("unterminated quoted value at offset %d", i)
+		}
+		values = append(values, line[i+1:end])
+		i = end + 1
+	}
+	return values, nil
+}
+
+// splitTabs splits a line into tab-separated values
+func splitTabs(line string) ([]string, error) {
+	return strings.Split(line, "\t"), nil
+}
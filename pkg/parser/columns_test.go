@@ -0,0 +1,22 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitQuoted(t *testing.T) {
+	t.Run("split quoted values", func(t *testing.T) {
+		values, err := splitQuoted(`http 200  "GET / HTTP/1.1" "curl \"quoted\"" "" -`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []string{"http", "200", "GET / HTTP/1.1", `curl \"quoted\"`, "", "-"}, values)
+	})
+
+	t.Run("unterminated quote returns error", func(t *testing.T) {
+		_, err := splitQuoted(`http "GET / HTTP/1.1`)
+		assert.Error(t, err)
+	})
+}
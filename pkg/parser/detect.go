@@ -0,0 +1,144 @@
+package parser
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// genericFormats are the formats that also parse the lines of more specific formats (e.g. the
+// nginx and apache formats default to the combined format, and CloudFront logs are W3C logs).
+// They rank below the specific formats parsing as many lines.
+var genericFormats = map[string]bool{
+	"apache": true,
+	"nginx":  true,
+	"w3c":    true,
+}
+
+// Candidate is the score of a log format on a sample of lines
+type Candidate struct {
+	// Format is the name of the log format
+	Format string
+	// Matched is the number of log entries parsed by the format, out of Total. Lines that the
+	// format skips (e.g. W3C directives) are not counted.
+	Matched int
+	Total   int
+	// Richness is the average number of Request fields set by the format per parsed entry
+	Richness float64
+
+	generic  bool
+	requests []Request
+}
+
+// MatchRate returns the share of the log entries parsed by the format
+func (c *Candidate) MatchRate() float64 {
+	if c.Total == 0 {
+		return 0
+	}
+	return float64(c.Matched) / float64(c.Total)
+}
+
+// Detection is the result of the detection of the log format of a sample of lines
+type Detection struct {
+	// Format is the detected log format
+	Format string
+	// Candidates are the formats that parsed at least one line, best first
+	Candidates []*Candidate
+}
+
+// Best returns the Candidate of the detected format
+func (d *Detection) Best() *Candidate {
+	return d.Candidates[0]
+}
+
+// Detect scores every registered format, with its default options, on a sample of lines (e.g.
+// the first lines of a log) and returns the format parsing the most lines. Ties are broken by
+// the number of Request fields set (e.g. the combined format over the common format on combined
+// logs), and the specific formats rank above the generic ones. It returns an error if no format
+// parses any line, or if several formats parse the lines equally well into different Requests.
+func Detect(lines []string) (*Detection, error) {
+	candidates := []*Candidate{}
+	for _, name := range Formats() {
+		p, err := New(name, nil)
+		if err != nil {
+			// the format cannot be used without options (e.g. grok)
+			continue
+		}
+
+		c := score(name, p, lines)
+		if c.Matched > 0 {
+			candidates = append(candidates, c)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("none of the formats parses the sampled lines")
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.MatchRate() != b.MatchRate() {
+			return a.MatchRate() > b.MatchRate()
+		}
+		if a.generic != b.generic {
+			return !a.generic
+		}
+		return a.Richness > b.Richness
+	})
+
+	best := candidates[0]
+	ambiguous := []string{best.Format}
+	for _, c := range candidates[1:] {
+		if c.MatchRate() != best.MatchRate() || c.generic != best.generic || c.Richness != best.Richness {
+			break
+		}
+		if !reflect.DeepEqual(c.requests, best.requests) {
+			ambiguous = append(ambiguous, c.Format)
+		}
+	}
+	if len(ambiguous) > 1 {
+		return nil, fmt.Errorf("ambiguous log format, the sampled lines are parsed as well by: %s", strings.Join(ambiguous, ", "))
+	}
+
+	return &Detection{Format: best.Format, Candidates: candidates}, nil
+}
+
+// score parses the lines with a Parser of the named format
+func score(name string, p Parser, lines []string) *Candidate {
+	c := &Candidate{Format: name, generic: genericFormats[name]}
+	fields := 0
+	for _, line := range lines {
+		r, err := p.ParseLine(line)
+		if err == ErrSkipLine {
+			continue
+		}
+		c.Total++
+		if err != nil {
+			continue
+		}
+
+		c.Matched++
+		c.requests = append(c.requests, r.Request)
+		fields += requestFields(&r.Request)
+	}
+
+	if c.Matched > 0 {
+		c.Richness = float64(fields) / float64(c.Matched)
+	}
+	return c
+}
+
+// requestFields returns the number of fields of the Request that are set
+func requestFields(r *Request) int {
+	n := 0
+	for _, set := range []bool{
+		r.RemoteHost != "", r.RemoteLogname != "", r.AuthUser != "", r.Timestamp != nil,
+		r.Method != "", r.URI != "", r.HTTPVersion != "", r.StatusCode != 0,
+		r.ResponseSizeBytes != 0, r.Referer != "", r.UserAgent != "", r.Duration != nil,
+	} {
+		if set {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,168 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// prefixParser parses the lines starting with prefix into a Request for the rest of the line
+type prefixParser struct {
+	prefix string
+	field  string
+}
+
+func (p *prefixParser) ParseLine(line string) (*Record, error) {
+	if !strings.HasPrefix(line, p.prefix) {
+		return nil, fmt.Errorf("missing prefix")
+	}
+	r := NewRecord()
+	err := r.SetField(p.field, strings.TrimPrefix(line, p.prefix))
+	return r, err
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		lines  []string
+	}{
+		{
+			name:   "detect common log format",
+			format: "common",
+			lines: []string{
+				`127.0.0.1 - james [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200 123`,
+				`127.0.0.1 - jill [09/May/2018:16:00:41 +0000] "GET /api/user HTTP/1.0" 200 234`,
+			},
+		},
+		{
+			name:   "detect combined log format",
+			format: "combined",
+			lines: []string{
+				`127.0.0.1 - james [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200 123 "http://example.com/home" "Mozilla/5.0 (X11; Linux x86_64)"`,
+				`127.0.0.1 - - [09/May/2018:16:00:40 +0000] "GET /report HTTP/1.0" 200 123 "-" "curl/7.58.0"`,
+			},
+		},
+		{
+			name:   "detect json logs",
+			format: "json",
+			lines: []string{
+				`{"time":"2018-05-09T16:00:39Z","remote_addr":"10.0.0.1","method":"GET","uri":"/api/users?id=1","status":200,"size":612}`,
+				`{"time":"2018-05-09T16:00:40Z","remote_addr":"10.0.0.2","method":"POST","uri":"/report","status":201,"size":12}`,
+			},
+		},
+		{
+			name:   "detect logfmt logs",
+			format: "logfmt",
+			lines: []string{
+				`at=info method=GET path="/api/users?id=1" host=example.com fwd="10.0.0.1" dyno=web.1 connect=1ms service=18ms status=200 bytes=612 protocol=https`,
+				`at=info method=POST path=/report host=example.com fwd="10.0.0.2" dyno=web.2 connect=0ms service=5ms status=201 bytes=12 protocol=https`,
+			},
+		},
+		{
+			name:   "detect haproxy logs",
+			format: "haproxy",
+			lines: []string{
+				`Feb  6 12:14:14 localhost haproxy[14389]: 10.0.1.2:33317 [06/Feb/2009:12:14:14.655] http-in static/srv1 10/0/30/69/109 200 2750 - - ---- 1/1/1/1/0 0/0 {1wt.eu} {} "GET /index.html HTTP/1.1"`,
+			},
+		},
+		{
+			name:   "detect syslog logs",
+			format: "syslog",
+			lines: []string{
+				`<134>May  9 16:00:39 lb01 nginx[1234]: 127.0.0.1 - james [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200 123`,
+				`<14>1 2018-05-09T16:00:39Z lb01 - - - - 127.0.0.1 - - [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200 123`,
+			},
+		},
+		{
+			name:   "detect alb logs",
+			format: "alb",
+			lines: []string{
+				`http 2018-11-30T22:23:00.186641Z app/my-loadbalancer/50dc6c495c0c9188 192.168.131.39:2817 - -1 -1 -1 503 - 34 366 "GET http://www.example.com:80/ HTTP/1.1" "curl/7.46.0" - -`,
+			},
+		},
+		{
+			name:   "detect elb logs",
+			format: "elb",
+			lines: []string{
+				`2015-05-13T23:39:43.945958Z my-loadbalancer 192.168.131.39:2817 10.0.0.1:80 0.000073 0.001048 0.000057 404 404 0 29 "GET http://www.example.com:80/images/logo.png HTTP/1.1" "curl/7.38.0" - -`,
+			},
+		},
+		{
+			name:   "detect cloudfront logs over w3c",
+			format: "cloudfront",
+			lines: []string{
+				"#Version: 1.0",
+				"#Fields: date time x-edge-location sc-bytes c-ip cs-method cs(Host) cs-uri-stem sc-status cs(Referer) cs(User-Agent) cs-uri-query",
+				"2019-12-04\t21:02:31\tLAX1\t392\t192.0.2.100\tGET\td111111abcdef8.cloudfront.net\t/index.html\t200\t-\tMozilla/5.0%20(Windows%20NT%2010.0)\t-",
+			},
+		},
+		{
+			name:   "detect w3c logs",
+			format: "w3c",
+			lines: []string{
+				"#Software: Microsoft Internet Information Services 10.0",
+				"#Fields: date time s-ip cs-method cs-uri-stem cs-uri-query s-port cs-username c-ip cs(User-Agent) cs(Referer) sc-status sc-substatus sc-win32-status time-taken",
+				"2019-12-04 21:02:31 10.0.0.4 GET /api/users id=1 443 - 192.0.2.100 Mozilla/5.0+(Windows+NT+10.0) - 200 0 0 15",
+			},
+		},
+		{
+			name:   "detect the format of most lines",
+			format: "combined",
+			lines: []string{
+				`127.0.0.1 - james [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200 123 "-" "curl/7.58.0"`,
+				`127.0.0.1 - james [09/May/2018:16:00:40 +0000] "GET /report HTTP/1.0" 200 123 "-" "curl/7.58.0"`,
+				`garbage`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			d, err := Detect(test.lines)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, test.format, d.Format)
+			assert.Equal(t, test.format, d.Best().Format)
+		})
+	}
+
+	t.Run("report match rate", func(t *testing.T) {
+		d, err := Detect([]string{
+			`127.0.0.1 - james [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200 123`,
+			`not a log entry`,
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "common", d.Format)
+		assert.Equal(t, 1, d.Best().Matched)
+		assert.Equal(t, 2, d.Best().Total)
+		assert.Equal(t, 0.5, d.Best().MatchRate())
+	})
+
+	t.Run("fail on ambiguous format", func(t *testing.T) {
+		Register("test-detect-uri", func(Options) (Parser, error) {
+			return &prefixParser{prefix: "test-detect ", field: "request_uri"}, nil
+		})
+		Register("test-detect-user", func(Options) (Parser, error) {
+			return &prefixParser{prefix: "test-detect ", field: "user"}, nil
+		})
+
+		_, err := Detect([]string{"test-detect /report"})
+		assert.EqualError(t, err, "ambiguous log format, the sampled lines are parsed as well by: test-detect-uri, test-detect-user")
+	})
+
+	t.Run("fail on unknown format", func(t *testing.T) {
+		_, err := Detect([]string{"not a log entry", "neither is this"})
+		assert.Error(t, err)
+	})
+
+	t.Run("fail on empty sample", func(t *testing.T) {
+		_, err := Detect(nil)
+		assert.Error(t, err)
+	})
+}
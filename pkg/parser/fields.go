@@ -0,0 +1,229 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldType is the type of the value of an extra field of a Record
+type FieldType string
+
+const (
+	// StringField values are kept as is
+	StringField FieldType = "string"
+	// IntField values are converted to int64
+	IntField FieldType = "int"
+	// FloatField values are converted to float64
+	FloatField FieldType = "float"
+)
+
+// The layouts of the datetime field, besides time.Parse() layouts, for numeric timestamps
+const (
+	unixLayout      = "unix"
+	unixMilliLayout = "unix_ms"
+	unixMicroLayout = "unix_us"
+	unixNanoLayout  = "unix_ns"
+)
+
+// unixScales are the number of units per second of the numeric timestamp layouts
+var unixScales = map[string]float64{
+	unixLayout:      1,
+	unixMilliLayout: 1e3,
+	unixMicroLayout: 1e6,
+	unixNanoLayout:  1e9,
+}
+
+// isUnixLayout returns true if the layout is one of the numeric timestamp layouts
+func isUnixLayout(layout string) bool {
+	_, ok := unixScales[layout]
+	return ok
+}
+
+// parseTimestamp parses the request time in the given layout, either a time.Parse() layout or
+// one of the numeric timestamp layouts.
+func parseTimestamp(value, layout string) (time.Time, error) {
+	scale, ok := unixScales[layout]
+	if !ok {
+		return time.Parse(layout, value)
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unexpected value %q", value)
+	}
+	return time.Unix(0, int64(f/scale*1e9)).UTC(), nil
+}
+
+// durationUnits are the values of the `duration_unit` option
+var durationUnits = map[string]time.Duration{
+	"s":  time.Second,
+	"ms": time.Millisecond,
+	"us": time.Microsecond,
+	"ns": time.Nanosecond,
+}
+
+// durationUnit returns the unit set by the `duration_unit` option of a structured log format,
+// or 0 if it is not set.
+func durationUnit(options Options) (time.Duration, error) {
+	value, ok := options["duration_unit"]
+	if !ok || value == "" {
+		return 0, nil
+	}
+	unit, ok := durationUnits[value]
+	if !ok {
+		return 0, fmt.Errorf("invalid duration_unit %q, expected one of s, ms, us or ns", value)
+	}
+	return unit, nil
+}
+
+// fieldMapping merges the options of a structured log format into its default mapping of Record
+// fields (see SetField, and `datetime` for the request time) onto keys. An option with an empty
+// key unmaps the field. It also returns the `time_layout` option, which defaults to RFC 3339.
+// The `duration_unit` option is read by durationUnit.
+func fieldMapping(defaults map[string]string, options Options) (map[string]string, string) {
+	mapping := make(map[string]string, len(defaults))
+	for field, key := range defaults {
+		mapping[field] = key
+	}
+
+	timeLayout := time.RFC3339Nano
+	for field, key := range options {
+		switch {
+		case field == "time_layout":
+			timeLayout = key
+		case field == "duration_unit":
+		case key == "":
+			delete(mapping, field)
+		default:
+			mapping[field] = key
+		}
+	}
+	return mapping, timeLayout
+}
+
+// parseValue converts the textual value of a field to the given type. Missing values, i.e.
+// empty or `-`, are reported as not ok.
+func parseValue(value string, t FieldType) (interface{}, bool) {
+	if value == "" || value == missingValue {
+		return nil, false
+	}
+
+	switch t {
+	case IntField:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		return i, true
+	case FloatField:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, false
+		}
+		return f, true
+	default:
+		return value, true
+	}
+}
+
+// SetTypedField sets an extra field of the Record, converting its value to the given type.
+// Missing values are not set, and values that cannot be converted are kept as strings, so
+// that one unexpected value does not cause the whole line to be rejected.
+func (r *Record) SetTypedField(name, value string, t FieldType) {
+	if v, ok := parseValue(value, t); ok {
+		r.Fields[name] = v
+	} else if value != "" && value != missingValue {
+		r.Fields[name] = value
+	}
+}
+
+// durationFields are the Fields holding the time taken to serve the request, in order of
+// precedence, along with the unit of their numeric values. The fields without a unit only
+// hold durations with a unit (e.g. `18ms`). The unit of `duration` varies between loggers
+// (e.g. seconds for Caddy, milliseconds for Envoy), so it is set by the format, if at all.
+var durationFields = []struct {
+	name string
+	unit time.Duration
+}{
+	{"duration_us", time.Microsecond},        // Apache %D
+	{"duration_ms", time.Millisecond},        // Apache %{ms}T, JSON and logfmt logs
+	{"request_time", time.Second},            // nginx $request_time
+	{"total_time_ms", time.Millisecond},      // HAProxy Ta
+	{"target_processing_time", time.Second},  // AWS ALB
+	{"backend_processing_time", time.Second}, // AWS ELB
+	{"duration", 0},                          // JSON and logfmt logs, e.g. `duration=12ms`
+	{"duration_s", time.Second},              // Apache %T
+	{"service", 0},                           // Heroku router, e.g. `service=18ms`
+}
+
+// setDuration sets the Duration of the Request from the first of the durationFields that holds
+// a valid duration. Numeric values of the `duration` field are in durationUnit, and ignored if
+// it is 0. Negative values, logged by some formats for requests that did not reach the server
+// (e.g. -1), are ignored.
+func (r *Record) setDuration(durationUnit time.Duration) {
+	for _, f := range durationFields {
+		unit := f.unit
+		if f.name == "duration" {
+			unit = durationUnit
+		}
+		if d, ok := durationValue(r.Fields[f.name], unit); ok {
+			r.Duration = &d
+			return
+		}
+	}
+}
+
+// durationValue converts the value of a field to a time.Duration, numeric values being in the
+// given unit
+func durationValue(v interface{}, unit time.Duration) (time.Duration, bool) {
+	if d, ok := v.(time.Duration); ok {
+		return d, d >= 0
+	}
+	if s, ok := v.(string); ok {
+		if d, err := time.ParseDuration(s); err == nil {
+			return d, d >= 0
+		}
+	}
+	if unit == 0 {
+		return 0, false
+	}
+
+	var f float64
+	switch v := v.(type) {
+	case int64:
+		f = float64(v)
+	case float64:
+		f = v
+	case string:
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, false
+		}
+		f = n
+	default:
+		return 0, false
+	}
+
+	if f < 0 || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, false
+	}
+	return time.Duration(math.Round(f * float64(unit))), true
+}
+
+// setRequestLine sets the method, URI and HTTP version of the Request from a request line
+// (e.g. `GET /index.html HTTP/1.1`). Malformed request lines set as much as can be read.
+func (r *Record) setRequestLine(line string) {
+	parts := strings.Fields(line)
+	if len(parts) > 0 {
+		r.Method = parts[0]
+	}
+	if len(parts) > 1 {
+		r.URI = parts[1]
+	}
+	if len(parts) > 2 {
+		r.HTTPVersion = strings.TrimPrefix(parts[2], "HTTP/")
+	}
+}
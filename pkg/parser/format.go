@@ -0,0 +1,97 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// formatToken is a piece of a log format definition (e.g. an nginx `log_format` or an Apache
+// `LogFormat`): either literal text, or a field whose value is read from the line.
+type formatToken struct {
+	// literal is the text of a literal token
+	literal string
+
+	// field is the name of the Record field (see SetField) set by a field token
+	field string
+	// fieldType is the type of an extra field
+	fieldType FieldType
+	// timeLayout is set if the field holds the request time, in this time.Parse() layout
+	timeLayout string
+}
+
+// appendToken appends a token to the tokens of a log format, merging consecutive literals
+func appendToken(tokens []formatToken, token formatToken) []formatToken {
+	if token.field == "" && len(tokens) > 0 && tokens[len(tokens)-1].field == "" {
+		tokens[len(tokens)-1].literal += token.literal
+		return tokens
+	}
+	return append(tokens, token)
+}
+
+// compileFormat compiles the tokens of a log format into a RegexParser. The value of a field
+// extends up to the first character of the literal that follows it. Only the first field
+// holding the request time sets the timestamp, and only the first occurrence of a field
+// is captured.
+func compileFormat(tokens []formatToken) (*RegexParser, error) {
+	var expr strings.Builder
+	expr.WriteString("^")
+
+	names := map[string]bool{}
+	types := map[string]FieldType{}
+	timeLayout := ""
+
+	for i, token := range tokens {
+		if token.field == "" {
+			expr.WriteString(regexp.QuoteMeta(token.literal))
+			continue
+		}
+
+		value := ".*"
+		if i+1 < len(tokens) {
+			next := tokens[i+1]
+			if next.field != "" || next.literal == "" {
+				value = `\S*?`
+			} else {
+				c, _ := utf8.DecodeRuneInString(next.literal)
+				value = "[^" + regexp.QuoteMeta(string(c)) + "]*"
+			}
+		}
+
+		name := token.field
+		if token.timeLayout != "" && timeLayout == "" {
+			name = "datetime"
+			timeLayout = token.timeLayout
+		}
+
+		if names[name] {
+			fmt.Fprintf(&expr, "(?:%s)", value)
+			continue
+		}
+		names[name] = true
+		if token.fieldType != "" {
+			types[name] = token.fieldType
+		}
+		fmt.Fprintf(&expr, "(?P<%s>%s)", name, value)
+	}
+	expr.WriteString("$")
+
+	lineFormat, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, err
+	}
+
+	p := NewRegexParser(lineFormat, timeLayout)
+	p.Types = types
+	return p, nil
+}
+
+// headerField returns the name of the field holding an HTTP header, following the nginx
+// convention, e.g. `X-Forwarded-For` becomes `<prefix>x_forwarded_for`.
+func headerField(prefix, header string) string {
+	return prefix + nonWord.ReplaceAllString(strings.ToLower(header), "_")
+}
+
+// nonWord matches the characters that are not allowed in field names
+var nonWord = regexp.MustCompile(`\W`)
@@ -0,0 +1,266 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// grokPatterns is the built-in pattern library, adapted from the Logstash grok patterns to the
+// RE2 syntax (i.e. without lookarounds and atomic groups). The web server log patterns capture
+// the Record fields (see SetField).
+var grokPatterns = map[string]string{
+	"USERNAME":          `[a-zA-Z0-9._-]+`,
+	"USER":              `%{USERNAME}`,
+	"EMAILLOCALPART":    `[a-zA-Z0-9!#$%&'*+/=?^_{|}~-]+(?:\.[a-zA-Z0-9!#$%&'*+/=?^_{|}~-]+)*`,
+	"EMAILADDRESS":      `%{EMAILLOCALPART}@%{HOSTNAME}`,
+	"INT":               `(?:[+-]?(?:[0-9]+))`,
+	"BASE10NUM":         `(?:[+-]?(?:[0-9]+(?:\.[0-9]+)?|\.[0-9]+))`,
+	"NUMBER":            `(?:%{BASE10NUM})`,
+	"BASE16NUM":         `(?:[+-]?(?:0x)?(?:[0-9A-Fa-f]+))`,
+	"POSINT":            `\b(?:[1-9][0-9]*)\b`,
+	"NONNEGINT":         `\b(?:[0-9]+)\b`,
+	"WORD":              `\b\w+\b`,
+	"NOTSPACE":          `\S+`,
+	"SPACE":             `\s*`,
+	"DATA":              `.*?`,
+	"GREEDYDATA":        `.*`,
+	"QUOTEDSTRING":      `(?:"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*')`,
+	"QS":                `%{QUOTEDSTRING}`,
+	"UUID":              `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+	"IPV4":              `(?:(?:25[0-5]|2[0-4][0-9]|[01]?[0-9]?[0-9])\.){3}(?:25[0-5]|2[0-4][0-9]|[01]?[0-9]?[0-9])`,
+	"IPV6":              `(?:[0-9A-Fa-f]{0,4}:){2,7}(?:%{IPV4}|[0-9A-Fa-f]{0,4})`,
+	"IP":                `(?:%{IPV6}|%{IPV4})`,
+	"HOSTNAME":          `\b(?:[0-9A-Za-z][0-9A-Za-z-]{0,62})(?:\.(?:[0-9A-Za-z][0-9A-Za-z-]{0,62}))*\.?`,
+	"IPORHOST":          `(?:%{IP}|%{HOSTNAME})`,
+	"HOSTPORT":          `%{IPORHOST}:%{POSINT}`,
+	"UNIXPATH":          `(?:/[\w_%!$@:.,+~-]*)+`,
+	"WINPATH":           `(?:[A-Za-z]+:|\\)(?:\\[^\\?*]*)+`,
+	"PATH":              `(?:%{UNIXPATH}|%{WINPATH})`,
+	"URIPROTO":          `[A-Za-z][A-Za-z0-9+\-.]+`,
+	"URIHOST":           `%{IPORHOST}(?::%{POSINT})?`,
+	"URIPATH":           `(?:/[A-Za-z0-9$.+!*'(){},~:;=@#%&_\-]*)+`,
+	"URIPARAM":          `\?[A-Za-z0-9$.+!*'|(){},~@#%&/=:;_?\-\[\]<>]*`,
+	"URIPATHPARAM":      `%{URIPATH}(?:%{URIPARAM})?`,
+	"URI":               `%{URIPROTO}://(?:%{USER}(?::[^@]*)?@)?(?:%{URIHOST})?(?:%{URIPATHPARAM})?`,
+	"MONTH":             `\b(?:[Jj]an(?:uary)?|[Ff]eb(?:ruary)?|[Mm]ar(?:ch)?|[Aa]pr(?:il)?|[Mm]ay|[Jj]un(?:e)?|[Jj]ul(?:y)?|[Aa]ug(?:ust)?|[Ss]ep(?:tember)?|[Oo]ct(?:ober)?|[Nn]ov(?:ember)?|[Dd]ec(?:ember)?)\b`,
+	"MONTHNUM":          `(?:0?[1-9]|1[0-2])`,
+	"MONTHDAY":          `(?:(?:0[1-9])|(?:[12][0-9])|(?:3[01])|[1-9])`,
+	"DAY":               `(?:Mon(?:day)?|Tue(?:sday)?|Wed(?:nesday)?|Thu(?:rsday)?|Fri(?:day)?|Sat(?:urday)?|Sun(?:day)?)`,
+	"YEAR":              `(?:\d\d){1,2}`,
+	"HOUR":              `(?:2[0123]|[01]?[0-9])`,
+	"MINUTE":            `(?:[0-5][0-9])`,
+	"SECOND":            `(?:(?:[0-5]?[0-9]|60)(?:[:.,][0-9]+)?)`,
+	"TIME":              `%{HOUR}:%{MINUTE}(?::%{SECOND})`,
+	"DATE_US":           `%{MONTHNUM}[/-]%{MONTHDAY}[/-]%{YEAR}`,
+	"DATE_EU":           `%{MONTHDAY}[./-]%{MONTHNUM}[./-]%{YEAR}`,
+	"DATE":              `(?:%{DATE_US}|%{DATE_EU})`,
+	"DATESTAMP":         `%{DATE}[- ]%{TIME}`,
+	"ISO8601_TIMEZONE":  `(?:Z|[+-]%{HOUR}(?::?%{MINUTE}))`,
+	"TIMESTAMP_ISO8601": `%{YEAR}-%{MONTHNUM}-%{MONTHDAY}[T ]%{HOUR}:?%{MINUTE}(?::?%{SECOND})?%{ISO8601_TIMEZONE}?`,
+	"HTTPDATE":          `%{MONTHDAY}/%{MONTH}/%{YEAR}:%{TIME} %{INT}`,
+	"SYSLOGTIMESTAMP":   `%{MONTH} +%{MONTHDAY} %{TIME}`,
+	"LOGLEVEL":          `(?:[Aa]lert|ALERT|[Tt]race|TRACE|[Dd]ebug|DEBUG|[Nn]otice|NOTICE|[Ii]nfo|INFO|[Ww]arn(?:ing)?|WARN(?:ING)?|[Ee]rr(?:or)?|ERR(?:OR)?|[Cc]rit(?:ical)?|CRIT(?:ICAL)?|[Ff]atal|FATAL|[Ss]evere|SEVERE|[Ee]merg(?:ency)?|EMERG(?:ENCY)?)`,
+	"HTTPDUSER":         `(?:%{EMAILADDRESS}|%{USER})`,
+	"COMMONAPACHELOG":   `%{IPORHOST:remote_host} %{HTTPDUSER:rfc931} %{HTTPDUSER:user} \[%{HTTPDATE:datetime}\] "%{DATA:request}" %{NUMBER:status_code} %{NOTSPACE:response_size}`,
+	"COMBINEDAPACHELOG": `%{COMMONAPACHELOG} "%{DATA:referer}" "%{DATA:user_agent}"`,
+}
+
+// grokTimeLayouts are the time.Parse() layouts of the patterns holding a timestamp with a year.
+// Timestamps are parsed in the first layout they match.
+var grokTimeLayouts = map[string][]string{
+	"HTTPDATE":          {datetimeLayout},
+	"TIMESTAMP_ISO8601": iso8601Layouts(),
+}
+
+// iso8601Layouts returns the time.Parse() layouts of the timestamps matched by TIMESTAMP_ISO8601,
+// i.e. with a `T` or a space between the date and the time, with or without seconds, and with or
+// without a time zone (UTC is assumed). Fractions of a second are parsed by every layout.
+func iso8601Layouts() []string {
+	var layouts []string
+	for _, sep := range []string{"T", " "} {
+		for _, clock := range []string{"15:04:05", "15:04"} {
+			for _, zone := range []string{"Z07:00", "Z0700", ""} {
+				layouts = append(layouts, "2006-01-02"+sep+clock+zone)
+			}
+		}
+	}
+	return layouts
+}
+
+// grokTypes maps the types of typed captures (e.g. `%{NUMBER:bytes:int}`) onto field types
+var grokTypes = map[string]FieldType{
+	"int":    IntField,
+	"float":  FloatField,
+	"string": StringField,
+}
+
+// grokReference matches a reference to a pattern, e.g. `%{IP}`, `%{IP:remote_host}` or
+// `%{NUMBER:bytes:int}`
+var grokReference = regexp.MustCompile(`%\{(\w+)(?::(\w+))?(?::(\w+))?\}`)
+
+func init() {
+	Register("grok", func(options Options) (Parser, error) {
+		g := NewGrok()
+		if files := options["patterns_file"]; files != "" {
+			for _, path := range strings.Split(files, ",") {
+				if err := g.AddPatternsFromFile(strings.TrimSpace(path)); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if options["pattern"] == "" {
+			return nil, fmt.Errorf("set the pattern option, e.g. pattern=%%{COMMONAPACHELOG}")
+		}
+		return g.Compile(options["pattern"], options["time_layout"])
+	})
+}
+
+// Grok compiles grok expressions, i.e. regular expressions referencing named patterns (e.g.
+// `%{IPORHOST:remote_host} %{NUMBER:bytes:int}`), into Parsers.
+type Grok struct {
+	patterns map[string]string
+}
+
+// NewGrok returns a new Grok with the built-in pattern library (e.g. IP, NUMBER, QS, URIPATH,
+// HTTPDATE or COMBINEDAPACHELOG)
+func NewGrok() *Grok {
+	g := &Grok{patterns: make(map[string]string, len(grokPatterns))}
+	for name, pattern := range grokPatterns {
+		g.patterns[name] = pattern
+	}
+	return g
+}
+
+// AddPattern defines a pattern, replacing any pattern with the same name
+func (g *Grok) AddPattern(name, pattern string) {
+	g.patterns[name] = pattern
+}
+
+// AddPatterns defines the patterns read from r, one per line, as the name of the pattern
+// followed by whitespace and the pattern (e.g. `ENDPOINT /api/v[0-9]+/%{WORD}`). Blank lines
+// and lines starting with `#` are ignored.
+func (g *Grok) AddPatterns(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// the name is separated from the pattern by spaces or tabs
+		i := strings.IndexFunc(line, unicode.IsSpace)
+		if i < 0 {
+			return fmt.Errorf("line %d: expected a pattern name and a pattern", n)
+		}
+		g.AddPattern(line[:i], strings.TrimSpace(line[i:]))
+	}
+	return scanner.Err()
+}
+
+// AddPatternsFromFile defines the patterns read from a pattern file (see AddPatterns)
+func (g *Grok) AddPatternsFromFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := g.AddPatterns(f); err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+	return nil
+}
+
+// Compile compiles a grok expression into a RegexParser. Named captures (e.g. `%{IP:client}`)
+// compile to named subexpressions, so that captures named after Record fields (see SetField)
+// populate the Request, and the others populate Fields, converted to their type, if any (e.g.
+// `%{NUMBER:bytes:int}`). The `datetime` capture sets the timestamp, parsed according to
+// timeLayout, which defaults to the layout of the captured pattern (e.g. HTTPDATE).
+func (g *Grok) Compile(expr, timeLayout string) (*RegexParser, error) {
+	c := &grokCompiler{grok: g, types: map[string]FieldType{}}
+	if timeLayout != "" {
+		c.timeLayouts = []string{timeLayout}
+	}
+	expanded, err := c.expand(expr, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile grok expression %q: %s", expr, err)
+	}
+
+	lineFormat, err := regexp.Compile(expanded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile grok expression %q: %s", expr, err)
+	}
+	if lineFormat.SubexpIndex("datetime") >= 0 && len(c.timeLayouts) == 0 {
+		return nil, fmt.Errorf("unknown layout of the datetime capture, set the time_layout option")
+	}
+
+	p := NewRegexParser(lineFormat, "")
+	p.Types = c.types
+	p.timeLayouts = c.timeLayouts
+	return p, nil
+}
+
+// grokCompiler holds the state of the compilation of a grok expression
+type grokCompiler struct {
+	grok *Grok
+	// types are the types of the typed captures
+	types map[string]FieldType
+	// timeLayouts are the layouts of the datetime capture
+	timeLayouts []string
+}
+
+// expand replaces the pattern references of expr with their regular expressions. stack holds
+// the patterns being expanded, to detect recursive patterns.
+func (c *grokCompiler) expand(expr string, stack []string) (string, error) {
+	var err error
+	expanded := grokReference.ReplaceAllStringFunc(expr, func(ref string) string {
+		if err != nil {
+			return ""
+		}
+		m := grokReference.FindStringSubmatch(ref)
+		name, field, typ := m[1], m[2], m[3]
+
+		pattern, ok := c.grok.patterns[name]
+		if !ok {
+			err = fmt.Errorf("unknown pattern %s", name)
+			return ""
+		}
+		for _, s := range stack {
+			if s == name {
+				err = fmt.Errorf("recursive pattern %s", name)
+				return ""
+			}
+		}
+
+		var sub string
+		if sub, err = c.expand(pattern, append(stack, name)); err != nil {
+			return ""
+		}
+		if field == "" {
+			return "(?:" + sub + ")"
+		}
+
+		if typ != "" {
+			t, ok := grokTypes[typ]
+			if !ok {
+				err = fmt.Errorf("unknown type %s of %s", typ, field)
+				return ""
+			}
+			// the Request fields have their own type
+			if !isRequestField(field) {
+				c.types[field] = t
+			}
+		}
+		if field == "datetime" && len(c.timeLayouts) == 0 {
+			c.timeLayouts = grokTimeLayouts[name]
+		}
+		return "(?P<" + field + ">" + sub + ")"
+	})
+	return expanded, err
+}
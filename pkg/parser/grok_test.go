@@ -0,0 +1,202 @@
+package parser
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrok(t *testing.T) {
+	t.Run("parse common log format", func(t *testing.T) {
+		p, err := New("grok", Options{"pattern": "%{COMMONAPACHELOG}"})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		r, err := p.ParseLine(`127.0.0.1 - james [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200 -`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, time.Date(2018, 5, 9, 16, 0, 39, 0, time.UTC), r.Timestamp.UTC())
+		assert.Equal(t, "127.0.0.1", r.RemoteHost)
+		assert.Equal(t, "james", r.AuthUser)
+		assert.Equal(t, "GET", r.Method)
+		assert.Equal(t, "/report", r.URI)
+		assert.Equal(t, "1.0", r.HTTPVersion)
+		assert.Equal(t, 200, r.StatusCode)
+		assert.Equal(t, 0, r.ResponseSizeBytes)
+		assert.Empty(t, r.Fields)
+	})
+
+	t.Run("parse combined log format", func(t *testing.T) {
+		p, err := NewGrok().Compile("%{COMBINEDAPACHELOG}", "")
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		r, err := p.ParseLine(`example.com - - [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.1" 200 123 "http://example.com/home" "curl/7.58.0"`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "example.com", r.RemoteHost)
+		assert.Equal(t, 123, r.ResponseSizeBytes)
+		assert.Equal(t, "http://example.com/home", r.Referer)
+		assert.Equal(t, "curl/7.58.0", r.UserAgent)
+	})
+
+	t.Run("typed captures", func(t *testing.T) {
+		p, err := NewGrok().Compile(`^%{TIMESTAMP_ISO8601:datetime} %{LOGLEVEL:level} %{IP:remote_host} %{WORD:request_method} %{URIPATHPARAM:request_uri} %{INT:status_code:int} %{NUMBER:bytes:int} %{NUMBER:duration:float} %{QS:message}$`, "")
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		r, err := p.ParseLine(`2018-05-09T16:00:39.5Z INFO 2001:db8::1 POST /api/users?id=1 201 512 0.025 "created \"user\""`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, time.Date(2018, 5, 9, 16, 0, 39, 5e8, time.UTC), *r.Timestamp)
+		assert.Equal(t, "2001:db8::1", r.RemoteHost)
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/api/users?id=1", r.URI)
+		assert.Equal(t, 201, r.StatusCode)
+		assert.Equal(t, map[string]interface{}{
+			"level":    "INFO",
+			"bytes":    int64(512),
+			"duration": 0.025,
+			"message":  `"created \"user\""`,
+		}, r.Fields)
+	})
+
+	t.Run("alternative captures with the same name", func(t *testing.T) {
+		p, err := NewGrok().Compile(`^(?:%{IPV4:client}|%{HOSTNAME:client}) %{URIPATH:request_uri}$`, "")
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		r, err := p.ParseLine(`10.0.0.1 /home`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "10.0.0.1", r.Fields["client"])
+
+		r, err = p.ParseLine(`web-01.example.com /home`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "web-01.example.com", r.Fields["client"])
+	})
+
+	t.Run("time layout option", func(t *testing.T) {
+		_, err := NewGrok().Compile(`%{SYSLOGTIMESTAMP:datetime} %{GREEDYDATA:message}`, "")
+		assert.Error(t, err)
+
+		p, err := New("grok", Options{
+			"pattern":     `\[%{DATESTAMP:datetime}\] %{GREEDYDATA:message}`,
+			"time_layout": "02/01/2006 15:04:05",
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		r, err := p.ParseLine(`[09/05/2018 16:00:39] started`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, time.Date(2018, 5, 9, 16, 0, 39, 0, time.UTC), *r.Timestamp)
+	})
+
+	t.Run("iso8601 timestamps", func(t *testing.T) {
+		p, err := NewGrok().Compile(`%{TIMESTAMP_ISO8601:datetime} %{GREEDYDATA:message}`, "")
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		for _, test := range []struct {
+			value    string
+			expected time.Time
+		}{
+			{"2018-05-09T16:00:39Z", time.Date(2018, 5, 9, 16, 0, 39, 0, time.UTC)},
+			{"2018-05-09T16:00:39.250+02:00", time.Date(2018, 5, 9, 14, 0, 39, 250e6, time.UTC)},
+			{"2018-05-09 16:00:39", time.Date(2018, 5, 9, 16, 0, 39, 0, time.UTC)},
+			{"2018-05-09 16:00:39,250", time.Date(2018, 5, 9, 16, 0, 39, 250e6, time.UTC)},
+			{"2018-05-09T16:00:39+0200", time.Date(2018, 5, 9, 14, 0, 39, 0, time.UTC)},
+			{"2018-05-09 16:00", time.Date(2018, 5, 9, 16, 0, 0, 0, time.UTC)},
+		} {
+			r, err := p.ParseLine(test.value + " started")
+			if !assert.NoError(t, err, test.value) {
+				continue
+			}
+			assert.True(t, test.expected.Equal(*r.Timestamp), "%s parsed as %s", test.value, r.Timestamp)
+		}
+	})
+
+	t.Run("user-defined patterns", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "grok")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "patterns")
+		// like the stock pattern files, names may be separated from patterns by tabs or several spaces
+		patterns := "# application patterns\n\nAPIVERSION\tv[0-9]+\nENDPOINT   /api/%{APIVERSION:api_version}/%{WORD}\n"
+		if !assert.NoError(t, ioutil.WriteFile(path, []byte(patterns), 0644)) {
+			return
+		}
+
+		p, err := New("grok", Options{
+			"pattern":       `%{ENDPOINT:request_uri} %{POSINT:status_code}`,
+			"patterns_file": path,
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		r, err := p.ParseLine(`/api/v2/users 404`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "/api/v2/users", r.URI)
+		assert.Equal(t, 404, r.StatusCode)
+		assert.Equal(t, "v2", r.Fields["api_version"])
+
+		g := NewGrok()
+		assert.Error(t, g.AddPatterns(strings.NewReader("NOPATTERN\n")))
+
+		_, err = New("grok", Options{"pattern": "%{WORD}", "patterns_file": filepath.Join(dir, "missing")})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid expressions return error", func(t *testing.T) {
+		g := NewGrok()
+		g.AddPattern("LOOP", "a%{LOOP}")
+
+		for _, expr := range []string{
+			"%{UNKNOWN:field}",
+			"%{LOOP}",
+			"%{NUMBER:bytes:long}",
+			"%{WORD:field}(",
+		} {
+			_, err := g.Compile(expr, "")
+			assert.Error(t, err, expr)
+		}
+
+		_, err := New("grok", nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("unmatched line returns error", func(t *testing.T) {
+		p, err := NewGrok().Compile("%{COMMONAPACHELOG}", "")
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		_, err = p.ParseLine(`not an access log`)
+		assert.Error(t, err)
+	})
+}
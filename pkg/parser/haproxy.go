@@ -0,0 +1,67 @@
+package parser
+
+import (
+	"regexp"
+)
+
+// haproxyHTTPLogFormat is a regular expression that captures the fields of the HAProxy HTTP log
+// format (`option httplog`), optionally preceded by a syslog header, e.g.
+//
+//	haproxy[14389]: 10.0.1.2:33317 [06/Feb/2009:12:14:14.655] http-in static/srv1 10/0/30/69/109 200 2750 - - ---- 1/1/1/1/0 0/0 {1wt.eu} {} "GET /index.html HTTP/1.1"
+//
+// See: https://docs.haproxy.org/2.8/configuration.html#8.2.3
+var haproxyHTTPLogFormat = regexp.MustCompile(`^(?:.*?: )?` +
+	`(?P<remote_host>\S+):(?P<remote_port>\d+) \[(?P<datetime>[^\]]+)\] ` +
+	`(?P<frontend_name>\S+) (?P<backend_name>[^/\s]+)/(?P<server_name>\S+) ` +
+	`(?P<request_time_ms>[+-]?\d+)/(?P<queue_time_ms>[+-]?\d+)/(?P<connect_time_ms>[+-]?\d+)/(?P<response_time_ms>[+-]?\d+)/(?P<total_time_ms>[+-]?\d+) ` +
+	`(?P<status_code>-?\d+) (?P<response_size>\+?\d+) ` +
+	`(?P<captured_request_cookie>\S+) (?P<captured_response_cookie>\S+) (?P<termination_state>\S{4}) ` +
+	`(?P<actconn>\d+)/(?P<feconn>\d+)/(?P<beconn>\d+)/(?P<srv_conn>\d+)/(?P<retries>\+?\d+) ` +
+	`(?P<srv_queue>\d+)/(?P<backend_queue>\d+) ` +
+	`(?:\{(?P<captured_request_headers>[^}]*)\} )?(?:\{(?P<captured_response_headers>[^}]*)\} )?` +
+	`"(?P<request>[^"]*)"?$`)
+
+// haproxyTimeLayout is the time.Parse() layout of the accept date of HAProxy logs
+const haproxyTimeLayout = "02/Jan/2006:15:04:05"
+
+// haproxyTypes are the types of the extra fields of HAProxy logs. Timers are in milliseconds, and
+// -1 for the steps that were not reached (e.g. aborted connections). Captures are optional.
+var haproxyTypes = map[string]FieldType{
+	"captured_request_cookie":   StringField,
+	"captured_response_cookie":  StringField,
+	"captured_request_headers":  StringField,
+	"captured_response_headers": StringField,
+	"remote_port":               IntField,
+	"request_time_ms":           IntField,
+	"queue_time_ms":             IntField,
+	"connect_time_ms":           IntField,
+	"response_time_ms":          IntField,
+	"total_time_ms":             IntField,
+	"actconn":                   IntField,
+	"feconn":                    IntField,
+	"beconn":                    IntField,
+	"srv_conn":                  IntField,
+	"retries":                   IntField,
+	"srv_queue":                 IntField,
+	"backend_queue":             IntField,
+}
+
+func init() {
+	Register("haproxy", func(Options) (Parser, error) {
+		return NewHAProxyParser(), nil
+	})
+}
+
+// NewHAProxyParser returns a new Parser for the HAProxy HTTP log format. The client address,
+// request line, status code and bytes read populate the Request. The timers (Tq/TR, Tw, Tc, Tr
+// and Tt/Ta) populate request_time_ms, queue_time_ms, connect_time_ms, response_time_ms and
+// total_time_ms, and the other fields are named after the HAProxy documentation (e.g.
+// backend_name, server_name, termination_state or srv_conn). The total time sets the Duration of
+// the Request.
+func NewHAProxyParser() *RegexParser {
+	p := NewRegexParser(haproxyHTTPLogFormat, haproxyTimeLayout)
+	for name, t := range haproxyTypes {
+		p.Types[name] = t
+	}
+	return p
+}
@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHAProxyParser(t *testing.T) {
+	p, err := New("haproxy", nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	t.Run("parse http log line", func(t *testing.T) {
+		line := `Feb  6 12:14:14 localhost haproxy[14389]: 10.0.1.2:33317 [06/Feb/2009:12:14:14.655] http-in static/srv1 10/0/30/69/109 200 2750 - - ---- 1/1/1/1/0 0/0 {1wt.eu} {} "GET /index.html HTTP/1.1"`
+
+		r, err := p.ParseLine(line)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, time.Date(2009, 2, 6, 12, 14, 14, 655e6, time.UTC), *r.Timestamp)
+		assert.Equal(t, "10.0.1.2", r.RemoteHost)
+		assert.Equal(t, "GET", r.Method)
+		assert.Equal(t, "/index.html", r.URI)
+		assert.Equal(t, "1.1", r.HTTPVersion)
+		assert.Equal(t, 200, r.StatusCode)
+		assert.Equal(t, 2750, r.ResponseSizeBytes)
+		assert.Equal(t, map[string]interface{}{
+			"remote_port":              int64(33317),
+			"frontend_name":            "http-in",
+			"backend_name":             "static",
+			"server_name":              "srv1",
+			"request_time_ms":          int64(10),
+			"queue_time_ms":            int64(0),
+			"connect_time_ms":          int64(30),
+			"response_time_ms":         int64(69),
+			"total_time_ms":            int64(109),
+			"termination_state":        "----",
+			"actconn":                  int64(1),
+			"feconn":                   int64(1),
+			"beconn":                   int64(1),
+			"srv_conn":                 int64(1),
+			"retries":                  int64(0),
+			"srv_queue":                int64(0),
+			"backend_queue":            int64(0),
+			"captured_request_headers": "1wt.eu",
+		}, r.Fields)
+	})
+
+	t.Run("parse aborted request", func(t *testing.T) {
+		line := `10.0.1.2:33319 [06/Feb/2009:12:14:15.321] http-in~ www/<NOSRV> -1/-1/-1/-1/+8 -1 +212 - - CR-- 2/2/0/0/+1 0/0 "<BADREQ>`
+
+		r, err := p.ParseLine(line)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, -1, r.StatusCode)
+		assert.Equal(t, 212, r.ResponseSizeBytes)
+		assert.Equal(t, "<BADREQ>", r.Method)
+		assert.Equal(t, "http-in~", r.Fields["frontend_name"])
+		assert.Equal(t, "<NOSRV>", r.Fields["server_name"])
+		assert.Equal(t, int64(-1), r.Fields["response_time_ms"])
+		assert.Equal(t, int64(8), r.Fields["total_time_ms"])
+		assert.Equal(t, duration(8*time.Millisecond), r.Duration)
+		assert.Equal(t, int64(1), r.Fields["retries"])
+		assert.Equal(t, "CR--", r.Fields["termination_state"])
+		assert.NotContains(t, r.Fields, "captured_request_headers")
+	})
+
+	t.Run("parse tcp log line returns error", func(t *testing.T) {
+		_, err := p.ParseLine(`haproxy[14387]: 10.0.1.2:33313 [06/Feb/2009:12:12:51.443] fnt bck/srv1 0/0/5007 212 -- 0/0/0/0/3 0/0`)
+		assert.Error(t, err)
+	})
+}
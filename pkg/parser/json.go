@@ -0,0 +1,187 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// jsonDefaultMapping maps Record fields (see SetField) onto the JSON keys they are read from
+// by default. Options override the mapping, e.g. `request_uri=request.uri`.
+var jsonDefaultMapping = map[string]string{
+	"remote_host":    "remote_addr",
+	"user":           "user",
+	"datetime":       "time",
+	"request_method": "method",
+	"request_uri":    "uri",
+	"http_version":   "protocol",
+	"status_code":    "status",
+	"response_size":  "size",
+	"referer":        "referer",
+	"user_agent":     "user_agent",
+}
+
+func init() {
+	Register("json", func(options Options) (Parser, error) {
+		return NewJSONParser(options)
+	})
+}
+
+// JSONParser is a Parser for logs written as one JSON object per line. Configurable paths (e.g.
+// `request.uri`) are mapped onto the fields of the Record, and the remaining keys are kept in
+// Fields, named after their path. Missing keys are ignored.
+type JSONParser struct {
+	// paths maps Record fields onto the path of the JSON value they are read from
+	paths map[string][]string
+	// mapped holds the mapped paths, which are not kept in Fields
+	mapped map[string]bool
+	// timeLayout is the layout of the datetime field
+	timeLayout string
+	// durationUnit is the unit of the numeric values of the duration field, if set
+	durationUnit time.Duration
+}
+
+// NewJSONParser returns a new JSONParser. Options map a Record field (see SetField, and
+// `datetime` for the request time) to the dot-separated path of a JSON value, e.g.
+// `request_uri=request.uri`, overriding the default mapping. An empty path unmaps the field.
+// The `time_layout` option sets the time.Parse() layout of the datetime field (default RFC 3339),
+// or unix, unix_ms, unix_us or unix_ns for numeric timestamps. The `duration_unit` option (s, ms,
+// us or ns) sets the unit of numeric `duration` values, which are ignored otherwise.
+func NewJSONParser(options Options) (*JSONParser, error) {
+	mapping, timeLayout := fieldMapping(jsonDefaultMapping, options)
+	unit, err := durationUnit(options)
+	if err != nil {
+		return nil, err
+	}
+	p := &JSONParser{
+		paths:        make(map[string][]string, len(mapping)),
+		mapped:       make(map[string]bool, len(mapping)),
+		timeLayout:   timeLayout,
+		durationUnit: unit,
+	}
+	for field, path := range mapping {
+		p.paths[field] = strings.Split(path, ".")
+		p.mapped[path] = true
+	}
+
+	return p, nil
+}
+
+// ParseLine parses a line holding a JSON object
+func (p *JSONParser) ParseLine(line string) (*Record, error) {
+	var obj map[string]interface{}
+	d := json.NewDecoder(strings.NewReader(line))
+	d.UseNumber()
+	if err := d.Decode(&obj); err != nil {
+		return nil, fmt.Errorf("failed to parse log line: %s", err)
+	}
+
+	r := NewRecord()
+	for field, path := range p.paths {
+		v, ok := lookupPath(obj, path)
+		if !ok || v == nil {
+			continue
+		}
+
+		if field == "datetime" {
+			ts, err := p.parseTime(v)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse request timestamp: %s", err)
+			}
+			r.Timestamp = &ts
+			continue
+		}
+
+		if err := r.SetField(field, jsonString(v)); err != nil {
+			return nil, err
+		}
+	}
+
+	p.flatten(r, "", obj)
+	r.setDuration(p.durationUnit)
+	return r, nil
+}
+
+// flatten keeps the values of obj that are not mapped in the Fields of r, named after their path
+func (p *JSONParser) flatten(r *Record, prefix string, obj map[string]interface{}) {
+	for key, v := range obj {
+		path := prefix + key
+		if p.mapped[path] {
+			continue
+		}
+
+		if nested, ok := v.(map[string]interface{}); ok {
+			p.flatten(r, path+".", nested)
+			continue
+		}
+		if v != nil {
+			r.Fields[path] = jsonValue(v)
+		}
+	}
+}
+
+// parseTime parses the request time, either a string in the configured layout or a numeric
+// timestamp, in seconds unless set otherwise.
+func (p *JSONParser) parseTime(v interface{}) (time.Time, error) {
+	layout := p.timeLayout
+	if _, ok := v.(json.Number); ok && !isUnixLayout(layout) {
+		layout = unixLayout
+	}
+	return parseTimestamp(jsonString(v), layout)
+}
+
+// lookupPath returns the value at the given path of obj
+func lookupPath(obj map[string]interface{}, path []string) (interface{}, bool) {
+	var v interface{} = obj
+	for _, key := range path {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if v, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// jsonString returns the textual value of a JSON value. The first element of an array is used,
+// as some loggers write headers as arrays of values.
+func jsonString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case []interface{}:
+		if len(v) == 0 {
+			return ""
+		}
+		return jsonString(v[0])
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// jsonValue returns the Go value of a JSON value, with numbers converted to int64 or float64
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, e := range v {
+			values[i] = jsonValue(e)
+		}
+		return values
+	default:
+		return v
+	}
+}
@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONParser(t *testing.T) {
+	t.Run("default mapping", func(t *testing.T) {
+		p, err := New("json", nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		r, err := p.ParseLine(`{"time":"2018-05-09T16:00:39Z","remote_addr":"10.0.0.1","method":"GET","uri":"/api/users","status":200,"size":612,"duration_ms":12.5,"host":"example.com"}`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, time.Date(2018, 5, 9, 16, 0, 39, 0, time.UTC), *r.Timestamp)
+		assert.Equal(t, "10.0.0.1", r.RemoteHost)
+		assert.Equal(t, "GET", r.Method)
+		assert.Equal(t, "/api", r.Section())
+		assert.Equal(t, 200, r.StatusCode)
+		assert.Equal(t, 612, r.ResponseSizeBytes)
+		assert.Equal(t, map[string]interface{}{
+			"duration_ms": 12.5,
+			"host":        "example.com",
+		}, r.Fields)
+		assert.Equal(t, duration(12500*time.Microsecond), r.Duration)
+	})
+
+	t.Run("nested paths and numeric timestamps", func(t *testing.T) {
+		// e.g. Caddy
+		p, err := New("json", Options{
+			"datetime":       "ts",
+			"remote_host":    "request.remote_ip",
+			"request_method": "request.method",
+			"request_uri":    "request.uri",
+			"http_version":   "request.proto",
+			"user_agent":     "request.headers.User-Agent",
+			"duration_unit":  "s",
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		r, err := p.ParseLine(`{"level":"info","ts":1525881639.5,"request":{"remote_ip":"10.0.0.1","method":"GET","uri":"/report","proto":"HTTP/2.0","headers":{"User-Agent":["curl/7.58.0"]}},"status":404,"size":0,"duration":0.0012}`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, time.Date(2018, 5, 9, 16, 0, 39, 5e8, time.UTC), *r.Timestamp)
+		assert.Equal(t, "10.0.0.1", r.RemoteHost)
+		assert.Equal(t, "/report", r.URI)
+		assert.Equal(t, "2.0", r.HTTPVersion)
+		assert.Equal(t, "curl/7.58.0", r.UserAgent)
+		assert.Equal(t, 404, r.StatusCode)
+		assert.Equal(t, map[string]interface{}{
+			"level":    "info",
+			"duration": 0.0012,
+		}, r.Fields)
+		assert.Equal(t, duration(1200*time.Microsecond), r.Duration)
+	})
+
+	t.Run("numeric timestamp layouts", func(t *testing.T) {
+		p, err := NewJSONParser(Options{"time_layout": "unix_ms"})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		r, err := p.ParseLine(`{"time":1525881639000}`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, time.Date(2018, 5, 9, 16, 0, 39, 0, time.UTC), *r.Timestamp)
+	})
+
+	t.Run("missing keys are ignored", func(t *testing.T) {
+		p, err := NewJSONParser(Options{"request_uri": "request.uri"})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		r, err := p.ParseLine(`{"msg":"started","status":null}`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Nil(t, r.Timestamp)
+		assert.Equal(t, "", r.URI)
+		assert.Equal(t, map[string]interface{}{"msg": "started"}, r.Fields)
+	})
+
+	t.Run("unmapped fields are kept", func(t *testing.T) {
+		p, err := NewJSONParser(Options{"status_code": ""})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		r, err := p.ParseLine(`{"status":"ok"}`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, 0, r.StatusCode)
+		assert.Equal(t, map[string]interface{}{"status": "ok"}, r.Fields)
+	})
+
+	t.Run("invalid JSON returns error", func(t *testing.T) {
+		p, err := NewJSONParser(nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		_, err = p.ParseLine(`127.0.0.1 - - [09/May/2018:16:00:39 +0000] "GET / HTTP/1.0" 200 1`)
+		assert.Error(t, err)
+
+		_, err = p.ParseLine(`{"time":"yesterday"}`)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,195 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// logfmtDefaultMapping maps Record fields (see SetField) onto the logfmt keys they are read from
+// by default, following the Heroku router (e.g. `method=GET path="/" fwd="10.0.0.1" status=200
+// bytes=612`). Options override the mapping, e.g. `request_uri=uri`.
+var logfmtDefaultMapping = map[string]string{
+	"remote_host":    "fwd",
+	"datetime":       "time",
+	"request_method": "method",
+	"request_uri":    "path",
+	"status_code":    "status",
+	"response_size":  "bytes",
+	"referer":        "referer",
+	"user_agent":     "user_agent",
+}
+
+func init() {
+	Register("logfmt", func(options Options) (Parser, error) {
+		return NewLogfmtParser(options)
+	})
+}
+
+// LogfmtParser is a Parser for logs written as `key=value` pairs, e.g. `at=info method=GET
+// path=/x status=200 duration=12ms`. Configurable keys are mapped onto the fields of the Record,
+// and the remaining pairs are kept in Fields, with numeric values converted to int64 or float64
+// and durations (e.g. `12ms`) to time.Duration. Missing keys are ignored.
+type LogfmtParser struct {
+	// keys maps Record fields onto the key they are read from
+	keys map[string]string
+	// mapped holds the mapped keys, which are not kept in Fields
+	mapped map[string]bool
+	// timeLayout is the layout of the datetime field
+	timeLayout string
+	// durationUnit is the unit of the numeric values of the duration field, if set
+	durationUnit time.Duration
+}
+
+// NewLogfmtParser returns a new LogfmtParser. Options map a Record field (see SetField, and
+// `datetime` for the request time) to a key, e.g. `request_uri=uri`, overriding the default
+// mapping. An empty key unmaps the field. The `time_layout` option sets the time.Parse() layout
+// of the datetime field (default RFC 3339), or unix, unix_ms, unix_us or unix_ns for numeric
+// timestamps. The `duration_unit` option (s, ms, us or ns) sets the unit of numeric `duration`
+// values, which are ignored otherwise.
+func NewLogfmtParser(options Options) (*LogfmtParser, error) {
+	mapping, timeLayout := fieldMapping(logfmtDefaultMapping, options)
+	unit, err := durationUnit(options)
+	if err != nil {
+		return nil, err
+	}
+	p := &LogfmtParser{
+		keys:         mapping,
+		mapped:       make(map[string]bool, len(mapping)),
+		timeLayout:   timeLayout,
+		durationUnit: unit,
+	}
+	for _, key := range mapping {
+		p.mapped[key] = true
+	}
+	return p, nil
+}
+
+// ParseLine parses a line of logfmt pairs
+func (p *LogfmtParser) ParseLine(line string) (*Record, error) {
+	pairs, err := logfmtPairs(line)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse log line: %s", err)
+	}
+
+	r := NewRecord()
+	for field, key := range p.keys {
+		value, ok := pairs[key]
+		if !ok || value == "" {
+			continue
+		}
+
+		if field == "datetime" {
+			ts, err := parseTimestamp(value, p.timeLayout)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse request timestamp: %s", err)
+			}
+			r.Timestamp = &ts
+			continue
+		}
+
+		if err := r.SetField(field, value); err != nil {
+			return nil, err
+		}
+	}
+
+	for key, value := range pairs {
+		if !p.mapped[key] {
+			r.Fields[key] = logfmtValue(value)
+		}
+	}
+	r.setDuration(p.durationUnit)
+	return r, nil
+}
+
+// logfmtPairs splits a line into its key/value pairs. Values may be quoted, with Go escape
+// sequences (e.g. `msg="say \"hi\""`), and keys without a value (e.g. `debug`) have an empty value.
+func logfmtPairs(line string) (map[string]string, error) {
+	pairs := make(map[string]string)
+	hasValue := false
+
+	i := 0
+	for {
+		for i < len(line) && line[i] <= ' ' {
+			i++
+		}
+		if i >= len(line) {
+			break
+		}
+
+		start := i
+		for i < len(line) && line[i] > ' ' && line[i] != '=' && line[i] != '"' {
+			i++
+		}
+		key := line[start:i]
+		if key == "" {
+			return nil, fmt.Errorf("unexpected %q at offset %d", line[i], i)
+		}
+		if i >= len(line) || line[i] != '=' {
+			pairs[key] = ""
+			continue
+		}
+		i++
+		hasValue = true
+
+		if i < len(line) && line[i] == '"' {
+			end, err := logfmtQuoteEnd(line, i)
+			if err != nil {
+				return nil, err
+			}
+			value, err := strconv.Unquote(line[i:end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid value of %s: %s", key, err)
+			}
+			pairs[key] = value
+			i = end
+			continue
+		}
+
+		start = i
+		for i < len(line) && line[i] > ' ' {
+			i++
+		}
+		pairs[key] = line[start:i]
+	}
+
+	if !hasValue {
+		return nil, fmt.Errorf("no key=value pairs")
+	}
+	return pairs, nil
+}
+
+// logfmtQuoteEnd returns the index following the closing quote of the quoted value starting at
+// line[start]
+func logfmtQuoteEnd(line string, start int) (int, error) {
+	for i := start + 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated quoted value at offset %d", start)
+}
+
+// logfmtValue returns the Go value of a logfmt value: numbers are converted to int64 or float64,
+// and durations (e.g. `12ms` or `1m30s`) to time.Duration.
+func logfmtValue(value string) interface{} {
+	if value == "" || strings.IndexByte("+-.0123456789", value[0]) < 0 {
+		// not a number, which also rules out NaN and Inf
+		return value
+	}
+
+	if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return f
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return d
+	}
+	return value
+}
@@ -0,0 +1,142 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogfmtParser(t *testing.T) {
+	t.Run("default mapping", func(t *testing.T) {
+		p, err := New("logfmt", nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		r, err := p.ParseLine(`at=info method=GET path="/api/users?id=1" host=example.com fwd="10.0.0.1" dyno=web.1 connect=1ms service=18ms status=200 bytes=612 protocol=https`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "10.0.0.1", r.RemoteHost)
+		assert.Equal(t, "GET", r.Method)
+		assert.Equal(t, "/api/users?id=1", r.URI)
+		assert.Equal(t, "/api", r.Section())
+		assert.Equal(t, 200, r.StatusCode)
+		assert.Equal(t, 612, r.ResponseSizeBytes)
+		assert.Equal(t, map[string]interface{}{
+			"at":       "info",
+			"host":     "example.com",
+			"dyno":     "web.1",
+			"connect":  time.Millisecond,
+			"service":  18 * time.Millisecond,
+			"protocol": "https",
+		}, r.Fields)
+		assert.Equal(t, duration(18*time.Millisecond), r.Duration)
+	})
+
+	t.Run("custom mapping", func(t *testing.T) {
+		p, err := New("logfmt", Options{
+			"datetime":      "ts",
+			"request_uri":   "uri",
+			"response_size": "",
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		r, err := p.ParseLine(`ts=2018-05-09T16:00:39.5Z level=info method=POST uri=/report status=201 bytes=12 duration=1.5s attempts=2 ratio=0.25`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, time.Date(2018, 5, 9, 16, 0, 39, 5e8, time.UTC), *r.Timestamp)
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/report", r.URI)
+		assert.Equal(t, 201, r.StatusCode)
+		assert.Equal(t, 0, r.ResponseSizeBytes)
+		assert.Equal(t, map[string]interface{}{
+			"level":    "info",
+			"bytes":    int64(12),
+			"duration": 1500 * time.Millisecond,
+			"attempts": int64(2),
+			"ratio":    0.25,
+		}, r.Fields)
+		assert.Equal(t, duration(1500*time.Millisecond), r.Duration)
+	})
+
+	t.Run("numeric timestamps", func(t *testing.T) {
+		p, err := NewLogfmtParser(Options{"time_layout": "unix"})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		r, err := p.ParseLine(`time=1525881639 method=GET`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, time.Date(2018, 5, 9, 16, 0, 39, 0, time.UTC), *r.Timestamp)
+	})
+
+	t.Run("duration unit option", func(t *testing.T) {
+		p, err := NewLogfmtParser(nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		r, err := p.ParseLine(`method=GET duration=18`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Nil(t, r.Duration, "numeric durations have no unit by default")
+
+		p, err = NewLogfmtParser(Options{"duration_unit": "ms"})
+		if !assert.NoError(t, err) {
+			return
+		}
+		r, err = p.ParseLine(`method=GET duration=18`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, duration(18*time.Millisecond), r.Duration)
+		assert.NotContains(t, p.mapped, "ms", "the option is not a field mapping")
+
+		_, err = NewLogfmtParser(Options{"duration_unit": "minutes"})
+		assert.Error(t, err)
+	})
+
+	t.Run("quoted values and bare keys", func(t *testing.T) {
+		p, err := NewLogfmtParser(nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		r, err := p.ParseLine(`msg="say \"hi\"\tthere" user_agent="curl/7.58.0" empty= debug path=/`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "curl/7.58.0", r.UserAgent)
+		assert.Equal(t, "/", r.URI)
+		assert.Equal(t, map[string]interface{}{
+			"msg":   "say \"hi\"\tthere",
+			"empty": "",
+			"debug": "",
+		}, r.Fields)
+	})
+
+	t.Run("invalid lines return error", func(t *testing.T) {
+		p, err := NewLogfmtParser(nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		for _, line := range []string{
+			`127.0.0.1 - - [09/May/2018:16:00:39 +0000] "GET / HTTP/1.0" 200 1`,
+			`msg="unterminated`,
+			`="no key"`,
+			`status=ok`,
+			`time=yesterday`,
+		} {
+			_, err = p.ParseLine(line)
+			assert.Error(t, err, line)
+		}
+	})
+}
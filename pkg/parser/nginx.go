@@ -0,0 +1,257 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// nginxCombined is the `combined` log format predefined by nginx
+const nginxCombined = `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent"`
+
+// nginxFields maps the nginx variables describing the request onto Record fields (see SetField)
+var nginxFields = map[string]string{
+	"remote_addr":     "remote_host",
+	"remote_user":     "user",
+	"request":         "request",
+	"request_method":  "request_method",
+	"request_uri":     "request_uri",
+	"server_protocol": "http_version",
+	"status":          "status_code",
+	"body_bytes_sent": "response_size",
+	"http_referer":    "referer",
+	"http_user_agent": "user_agent",
+}
+
+// nginxTimeLayouts are the time.Parse() layouts of the nginx variables holding the request time
+var nginxTimeLayouts = map[string]string{
+	"time_local":   datetimeLayout,
+	"time_iso8601": time.RFC3339,
+}
+
+// nginxTypes are the types of the nginx variables with numeric values. Any other variable is
+// kept as a string field named after the variable.
+var nginxTypes = map[string]FieldType{
+	"request_time":             FloatField,
+	"upstream_response_time":   FloatField,
+	"upstream_connect_time":    FloatField,
+	"upstream_header_time":     FloatField,
+	"msec":                     FloatField,
+	"gzip_ratio":               FloatField,
+	"request_length":           IntField,
+	"bytes_sent":               IntField,
+	"body_bytes_sent":          IntField,
+	"content_length":           IntField,
+	"connection":               IntField,
+	"connection_requests":      IntField,
+	"upstream_status":          IntField,
+	"upstream_bytes_received":  IntField,
+	"upstream_bytes_sent":      IntField,
+	"upstream_response_length": IntField,
+	"remote_port":              IntField,
+	"server_port":              IntField,
+	"pid":                      IntField,
+}
+
+// nginxVariable matches a variable in a log_format string, e.g. `$status` or `${status}`
+var nginxVariable = regexp.MustCompile(`\$(?:\{(\w+)\}|(\w+))`)
+
+func init() {
+	Register("nginx", func(options Options) (Parser, error) {
+		format := options["log_format"]
+		if format == "" && options["config"] != "" {
+			name := options["name"]
+			if name == "" {
+				name = "combined"
+			}
+
+			var err error
+			format, err = nginxConfigLogFormat(options["config"], name)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if format == "" {
+			format = nginxCombined
+		}
+
+		return NewNginxParser(format)
+	})
+}
+
+// NewNginxParser compiles an nginx `log_format` string (e.g. `$remote_addr [$time_local] "$request"
+// $status $request_time`) into a Parser. Variables describing the request populate the Request,
+// the others populate Fields, named after the variable and typed according to their values.
+func NewNginxParser(format string) (*RegexParser, error) {
+	locs := nginxVariable.FindAllStringSubmatchIndex(format, -1)
+	if len(locs) == 0 {
+		return nil, fmt.Errorf("log format %q has no variables", format)
+	}
+
+	tokens := []formatToken{}
+	prev := 0
+	for _, loc := range locs {
+		if loc[0] > prev {
+			tokens = append(tokens, formatToken{literal: format[prev:loc[0]]})
+		}
+		prev = loc[1]
+
+		var variable string
+		if loc[2] >= 0 {
+			variable = format[loc[2]:loc[3]]
+		} else {
+			variable = format[loc[4]:loc[5]]
+		}
+
+		token := formatToken{field: variable, timeLayout: nginxTimeLayouts[variable]}
+		if name, ok := nginxFields[variable]; ok {
+			token.field = name
+		} else {
+			token.fieldType = nginxTypes[variable]
+		}
+		tokens = append(tokens, token)
+	}
+	if prev < len(format) {
+		tokens = append(tokens, formatToken{literal: format[prev:]})
+	}
+
+	p, err := compileFormat(tokens)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile log format %q: %s", format, err)
+	}
+	return p, nil
+}
+
+// nginxConfigLogFormat returns the log format with the given name defined in an nginx
+// configuration file. `include` directives are not followed.
+func nginxConfigLogFormat(path, name string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	format, err := findNginxLogFormat(f, name)
+	if err != nil {
+		return "", fmt.Errorf("%s: %s", path, err)
+	}
+	return format, nil
+}
+
+// nginxToken is a token of an nginx configuration file
+type nginxToken struct {
+	text string
+	// quoted is true for quoted strings, which are never directives or separators
+	quoted bool
+}
+
+// findNginxLogFormat returns the log format with the given name defined by a `log_format`
+// directive (e.g. `log_format main '$remote_addr ' '"$request"';`) in an nginx configuration.
+// Falls back to the predefined combined format for the name `combined`.
+func findNginxLogFormat(r io.Reader, name string) (string, error) {
+	tokens, err := nginxTokens(r)
+	if err != nil {
+		return "", err
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		if tokens[i].quoted || tokens[i].text != "log_format" {
+			continue
+		}
+
+		// log_format name [escape=default|json|none] string ...;
+		args := []nginxToken{}
+		for i++; i < len(tokens) && (tokens[i].quoted || tokens[i].text != ";"); i++ {
+			args = append(args, tokens[i])
+		}
+		if len(args) < 2 || args[0].text != name {
+			continue
+		}
+
+		var format strings.Builder
+		for _, arg := range args[1:] {
+			if !arg.quoted && strings.HasPrefix(arg.text, "escape=") {
+				continue
+			}
+			format.WriteString(arg.text)
+		}
+		return format.String(), nil
+	}
+
+	if name == "combined" {
+		return nginxCombined, nil
+	}
+	return "", fmt.Errorf("log format %q not found", name)
+}
+
+// nginxTokens splits an nginx configuration into tokens, skipping comments
+func nginxTokens(r io.Reader) ([]nginxToken, error) {
+	br := bufio.NewReader(r)
+	tokens := []nginxToken{}
+
+	var word strings.Builder
+	endWord := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, nginxToken{text: word.String()})
+			word.Reset()
+		}
+	}
+
+	for {
+		c, _, err := br.ReadRune()
+		if err == io.EOF {
+			endWord()
+			return tokens, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case unicode.IsSpace(c):
+			endWord()
+
+		case c == '#':
+			endWord()
+			if _, err := br.ReadString('\n'); err != nil && err != io.EOF {
+				return nil, err
+			}
+
+		case c == ';' || c == '{' || c == '}':
+			endWord()
+			tokens = append(tokens, nginxToken{text: string(c)})
+
+		case (c == '\'' || c == '"') && word.Len() == 0:
+			var quoted strings.Builder
+			for {
+				q, _, err := br.ReadRune()
+				if err != nil {
+					return nil, fmt.Errorf("unterminated string")
+				}
+				if q == c {
+					break
+				}
+				if q == '\\' {
+					escaped, _, err := br.ReadRune()
+					if err != nil {
+						return nil, fmt.Errorf("unterminated string")
+					}
+					if escaped != c && escaped != '\\' {
+						quoted.WriteRune(q)
+					}
+					q = escaped
+				}
+				quoted.WriteRune(q)
+			}
+			tokens = append(tokens, nginxToken{text: quoted.String(), quoted: true})
+
+		default:
+			word.WriteRune(c)
+		}
+	}
+}
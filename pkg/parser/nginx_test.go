@@ -0,0 +1,153 @@
+package parser
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNginxParser(t *testing.T) {
+	t.Run("default combined format", func(t *testing.T) {
+		p, err := New("nginx", nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		r, err := p.ParseLine(`10.0.0.1 - - [09/May/2018:16:00:39 +0000] "GET /api/users?id=1 HTTP/1.1" 200 612 "-" "curl/7.58.0"`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "10.0.0.1", r.RemoteHost)
+		assert.Equal(t, "-", r.AuthUser)
+		assert.Equal(t, 39, r.Timestamp.Second())
+		assert.Equal(t, "GET", r.Method)
+		assert.Equal(t, "/api/users?id=1", r.URI)
+		assert.Equal(t, "1.1", r.HTTPVersion)
+		assert.Equal(t, 200, r.StatusCode)
+		assert.Equal(t, 612, r.ResponseSizeBytes)
+		assert.Equal(t, "curl/7.58.0", r.UserAgent)
+	})
+
+	t.Run("custom format with typed fields", func(t *testing.T) {
+		p, err := NewNginxParser(`$remote_addr $host [$time_iso8601] "$request" $status $body_bytes_sent $request_time ${upstream_response_time} "$http_x_forwarded_for"`)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		r, err := p.ParseLine(`10.0.0.1 example.com [2018-05-09T16:00:39+00:00] "POST /login HTTP/2.0" 302 0 0.153 0.150 "203.0.113.7, 10.0.0.2"`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, time.Date(2018, 5, 9, 16, 0, 39, 0, time.UTC), r.Timestamp.UTC())
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, 302, r.StatusCode)
+		assert.Equal(t, map[string]interface{}{
+			"host":                   "example.com",
+			"request_time":           0.153,
+			"upstream_response_time": 0.150,
+			"http_x_forwarded_for":   "203.0.113.7, 10.0.0.2",
+		}, r.Fields)
+		assert.Equal(t, duration(153*time.Millisecond), r.Duration)
+	})
+
+	t.Run("missing values are not set", func(t *testing.T) {
+		p, err := NewNginxParser(`$remote_addr "$request" $status $upstream_response_time`)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		r, err := p.ParseLine(`10.0.0.1 "GET / HTTP/1.1" 499 -`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "/", r.Section())
+		assert.Empty(t, r.Fields)
+	})
+
+	t.Run("malformed request line", func(t *testing.T) {
+		p, err := NewNginxParser(`$remote_addr "$request" $status`)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		r, err := p.ParseLine(`10.0.0.1 "\x16\x03\x01" 400`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, 400, r.StatusCode)
+		assert.Equal(t, "/", r.Section())
+	})
+
+	t.Run("line not matching the format returns error", func(t *testing.T) {
+		p, err := NewNginxParser(`$remote_addr [$time_local] $status`)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		_, err = p.ParseLine(`10.0.0.1 09/May/2018:16:00:39 +0000 200`)
+		assert.Error(t, err)
+	})
+
+	t.Run("format without variables returns error", func(t *testing.T) {
+		_, err := NewNginxParser(`static`)
+		assert.Error(t, err)
+	})
+}
+
+func TestFindNginxLogFormat(t *testing.T) {
+	config := `
+http {
+    # log_format commented '$status';
+    log_format  main  escape=json '$remote_addr - $remote_user [$time_local] "$request" '
+                      '$status $body_bytes_sent "$http_referer" '
+                      "\"$http_user_agent\" $request_time";
+
+    access_log  /var/log/nginx/access.log  main;
+}
+`
+
+	t.Run("concatenates the strings of the directive", func(t *testing.T) {
+		format, err := findNginxLogFormat(strings.NewReader(config), "main")
+		assert.NoError(t, err)
+		assert.Equal(t, `$remote_addr - $remote_user [$time_local] "$request" $status $body_bytes_sent "$http_referer" "$http_user_agent" $request_time`, format)
+	})
+
+	t.Run("combined is predefined", func(t *testing.T) {
+		format, err := findNginxLogFormat(strings.NewReader(config), "combined")
+		assert.NoError(t, err)
+		assert.Equal(t, nginxCombined, format)
+	})
+
+	t.Run("unknown format returns error", func(t *testing.T) {
+		_, err := findNginxLogFormat(strings.NewReader(config), "commented")
+		assert.Error(t, err)
+	})
+
+	t.Run("config option reads the format from a file", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "dtail")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "nginx.conf")
+		if err := ioutil.WriteFile(path, []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		p, err := New("nginx", Options{"config": path, "name": "main"})
+		if !assert.NoError(t, err) {
+			return
+		}
+		r, err := p.ParseLine(`10.0.0.1 - - [09/May/2018:16:00:39 +0000] "GET / HTTP/1.1" 200 612 "-" "curl/7.58.0" 0.001`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, 0.001, r.Fields["request_time"])
+	})
+}
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 	"strconv"
@@ -12,6 +13,10 @@ import (
 // format. (see: https://www.w3.org/Daemon/User/Config/Logging.html#common-logfile-format)
 var commonLogFormat = regexp.MustCompile(`^(?P<remote_host>\S+) (?P<rfc931>\S+) (?P<user>\S+) \[(?P<datetime>[^\]]+)\] "(?P<request_method>[A-Z]+) (?P<request_uri>[^\s"]+) HTTP/(?P<http_version>[0-9.]+)" (?P<status_code>\d{3}) (?P<response_size>[\d-]+)`)
 
+// combinedLogFormat extends commonLogFormat with the referer and user agent fields of the NCSA Combined Log
+// Format, as written by default by Apache and nginx. Quotes within the fields are escaped with a backslash.
+var combinedLogFormat = regexp.MustCompile(commonLogFormat.String() + ` "(?P<referer>(?:[^"\\]|\\.)*)" "(?P<user_agent>(?:[^"\\]|\\.)*)"`)
+
 const (
 	// datetimeLayout is a time.Parse() compatible layout string formatted according to strftime format: `%d/%b/%Y:%H:%M:%S %z`
 	datetimeLayout = "02/Jan/2006:15:04:05 -0700"
@@ -20,6 +25,15 @@ const (
 	missingValue = "-"
 )
 
+func init() {
+	Register("common", func(Options) (Parser, error) {
+		return NewParser(), nil
+	})
+	Register("combined", func(Options) (Parser, error) {
+		return NewCombinedParser(), nil
+	})
+}
+
 // Request represents an HTTP request
 type Request struct {
 	RemoteHost        string
@@ -31,36 +45,104 @@ type Request struct {
 	HTTPVersion       string
 	StatusCode        int
 	ResponseSizeBytes int
+	Referer           string
+	UserAgent         string
+	// Duration is the time taken to serve the request, if the log format provides it (e.g. Apache
+	// %D, nginx $request_time or a `duration_ms` key)
+	Duration *time.Duration
 }
 
 // Section returns the website section, which is defined as the first path before the
-// second '/' in the URI.
+// second '/' in the URI. The scheme and host of absolute URIs (e.g. `http://example.com:80/api`,
+// as logged by load balancers and proxies) are ignored.
 func (r *Request) Section() string {
-	return "/" + strings.Split(r.URI, "/")[1]
+	uri := r.URI
+	if i := strings.Index(uri, "://"); i > 0 && !strings.Contains(uri[:i], "/") {
+		uri = uri[i+len("://"):]
+		if j := strings.IndexByte(uri, '/'); j >= 0 {
+			uri = uri[j:]
+		} else {
+			uri = "/"
+		}
+	}
+
+	parts := strings.Split(uri, "/")
+	if len(parts) < 2 {
+		// e.g. `OPTIONS *` or a malformed request
+		return "/"
+	}
+	return "/" + parts[1]
+}
+
+// Record is a parsed log line: the Request it describes, along with any other fields provided
+// by the log format (e.g. `upstream_response_time`), keyed by name.
+type Record struct {
+	Request
+	Fields map[string]interface{}
+}
+
+// NewRecord returns a new, empty, Record
+func NewRecord() *Record {
+	return &Record{Fields: make(map[string]interface{})}
 }
 
-// Parser is a log line parser
-type Parser struct {
+// ErrSkipLine is returned by ParseLine for lines that are not log entries, e.g. the `#Version`
+// and `#Fields` directives heading W3C logs. Such lines are expected, and not reported as errors.
+var ErrSkipLine = errors.New("not a log entry")
+
+// Parser parses log lines into Records
+type Parser interface {
+	// ParseLine parses a log line and returns any parsing errors, or ErrSkipLine
+	ParseLine(line string) (*Record, error)
+}
+
+// RegexParser is a Parser that matches lines against a regular expression. Named subexpressions
+// that are known request fields (see SetField) populate the Request, any other populates Fields.
+type RegexParser struct {
+	// Types sets the type of the values of extra fields, which are strings by default
+	Types map[string]FieldType
+
 	lineFormat *regexp.Regexp
+	// timeLayouts are the time.Parse() layouts of the `datetime` field, tried in order
+	timeLayouts []string
 }
 
 // NewParser initializes and returns a new Parser configured for Common LogFile format by default.
-func NewParser() *Parser {
-	return &Parser{lineFormat: commonLogFormat}
+func NewParser() Parser {
+	return NewRegexParser(commonLogFormat, datetimeLayout)
+}
+
+// NewCombinedParser returns a new Parser for the NCSA Combined Log Format, which adds the referer
+// and user agent to the Common Log Format.
+func NewCombinedParser() Parser {
+	return NewRegexParser(combinedLogFormat, datetimeLayout)
 }
 
-// fieldsByName maps all of the submatches to the regex subexpression names.
+// NewRegexParser returns a new RegexParser matching lines against lineFormat. The `datetime`
+// subexpression, if any, is parsed according to the time.Parse() layout timeLayout.
+func NewRegexParser(lineFormat *regexp.Regexp, timeLayout string) *RegexParser {
+	return &RegexParser{
+		Types:       make(map[string]FieldType),
+		lineFormat:  lineFormat,
+		timeLayouts: []string{timeLayout},
+	}
+}
+
+// fieldsByName maps all of the submatches to the regex subexpression names. Subexpressions may
+// share a name (e.g. `(?:(?P<host>...)|(?P<host>...))`), in which case the name is mapped to the
+// submatch that is not empty, if any.
 func fieldsByName(matches, fields []string) map[string]string {
 	m := make(map[string]string, len(matches))
 	for i, field := range fields {
-		m[field] = matches[i]
+		if _, ok := m[field]; !ok || matches[i] != "" {
+			m[field] = matches[i]
+		}
 	}
 	return m
 }
 
 // ParseLine parsers a line log line and returns any parsing errors
-func (p *Parser) ParseLine(line string) (*Request, error) {
-	var err error
+func (p *RegexParser) ParseLine(line string) (*Record, error) {
 	matches := p.lineFormat.FindStringSubmatch(line)
 
 	if len(matches) == 0 {
@@ -71,38 +153,107 @@ func (p *Parser) ParseLine(line string) (*Request, error) {
 	// first element of SubexpNames() is always empty string (see: https://golang.org/pkg/regexp/#Regexp.SubexpNames)
 	fields := fieldsByName(matches[1:], p.lineFormat.SubexpNames()[1:])
 
-	r := new(Request)
-	r.RemoteHost = fields["remote_host"]
-	r.RemoteLogname = fields["rfc931"]
-	r.AuthUser = fields["user"]
-	r.Method = fields["request_method"]
-	r.URI = fields["request_uri"]
-	r.HTTPVersion = fields["http_version"]
-
-	// TODO: don't set missing values to a default value, this will effect averages
-	status := 0
-	if fields["status_code"] != missingValue {
-		status, err = strconv.Atoi(fields["status_code"])
-		if err != nil {
-			return nil, fmt.Errorf("failed to convert HTTP status code to int")
+	r := NewRecord()
+	for name, value := range fields {
+		if name == "" {
+			continue
+		}
+		if name == "datetime" {
+			ts, err := p.parseTimestamp(value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse request timestamp: %s", err.Error())
+			}
+			r.Timestamp = &ts
+			continue
+		}
+		if t, ok := p.Types[name]; ok {
+			r.SetTypedField(name, value, t)
+			continue
+		}
+		if err := r.SetField(name, value); err != nil {
+			return nil, err
 		}
 	}
-	r.StatusCode = status
 
-	size := 0
-	if fields["response_size"] != missingValue {
-		size, err = strconv.Atoi(fields["response_size"])
-		if err != nil {
-			return nil, fmt.Errorf("failed to convert ResponseSize to int")
+	r.setDuration(0)
+	return r, nil
+}
+
+// parseTimestamp parses the `datetime` field in the first of the time layouts that it matches,
+// which may be numeric timestamp layouts (see parseTimestamp). If none does, it returns the
+// error of the first layout.
+func (p *RegexParser) parseTimestamp(value string) (time.Time, error) {
+	var first error
+	for _, layout := range p.timeLayouts {
+		ts, err := parseTimestamp(value, layout)
+		if err == nil {
+			return ts, nil
+		}
+		if first == nil {
+			first = err
 		}
 	}
-	r.ResponseSizeBytes = size
+	return time.Time{}, first
+}
 
-	ts, err := time.Parse(datetimeLayout, fields["datetime"])
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse request timestamp: %s", err.Error())
+// SetField sets a field of the Record from its textual value. The following names set the
+// corresponding Request field: remote_host, rfc931, user, request_method, request_uri,
+// http_version, status_code, response_size, referer and user_agent. `request` sets the
+// method, URI and HTTP version from a request line. Any other name sets the value in Fields.
+func (r *Record) SetField(name, value string) error {
+	var err error
+	switch name {
+	case "remote_host":
+		r.RemoteHost = value
+	case "rfc931":
+		r.RemoteLogname = value
+	case "user":
+		r.AuthUser = value
+	case "request_method":
+		r.Method = value
+	case "request_uri":
+		r.URI = value
+	case "http_version":
+		r.HTTPVersion = strings.TrimPrefix(value, "HTTP/")
+	case "request":
+		r.setRequestLine(value)
+	case "referer":
+		r.Referer = unescapeQuotes(value)
+	case "user_agent":
+		r.UserAgent = unescapeQuotes(value)
+
+	// TODO: don't set missing values to a default value, this will effect averages
+	case "status_code":
+		status := 0
+		if value != missingValue {
+			status, err = strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("failed to convert HTTP status code to int")
+			}
+		}
+		r.StatusCode = status
+
+	case "response_size":
+		size := 0
+		if value != missingValue {
+			size, err = strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("failed to convert ResponseSize to int")
+			}
+		}
+		r.ResponseSizeBytes = size
+
+	default:
+		r.Fields[name] = value
 	}
-	r.Timestamp = &ts
 
-	return r, nil
+	return nil
+}
+
+// unescapeQuotes removes the backslashes escaping the quotes and backslashes of a quoted field
+func unescapeQuotes(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(value)
 }
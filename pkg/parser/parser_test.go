@@ -1,7 +1,9 @@
 package parser
 
 import (
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -53,3 +55,138 @@ func TestCommonLogFormatParser(t *testing.T) {
 		assert.Equal(t, 0, r.ResponseSizeBytes)
 	})
 }
+
+func TestRegexParser(t *testing.T) {
+	p := NewRegexParser(
+		regexp.MustCompile(`^(?P<remote_host>\S+) \[(?P<datetime>[^\]]+)\] (?P<request_method>\S+) (?P<request_uri>\S+) (?P<status_code>\d+) (?P<host>\S+)$`),
+		time.RFC3339,
+	)
+
+	t.Run("maps known fields onto the request and others onto fields", func(t *testing.T) {
+		r, err := p.ParseLine("10.0.0.1 [2018-05-09T16:00:39Z] GET /api/users 404 example.com")
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, "10.0.0.1", r.RemoteHost)
+		assert.Equal(t, time.Date(2018, 5, 9, 16, 0, 39, 0, time.UTC), r.Timestamp.UTC())
+		assert.Equal(t, "GET", r.Method)
+		assert.Equal(t, "/api", r.Section())
+		assert.Equal(t, 404, r.StatusCode)
+		assert.Equal(t, map[string]interface{}{"host": "example.com"}, r.Fields)
+	})
+
+	t.Run("invalid timestamp returns error", func(t *testing.T) {
+		_, err := p.ParseLine("10.0.0.1 [yesterday] GET /api/users 404 example.com")
+		assert.Error(t, err)
+	})
+}
+
+func TestCombinedLogFormatParser(t *testing.T) {
+	p := NewCombinedParser()
+
+	t.Run("parse referer and user agent", func(t *testing.T) {
+		line := `127.0.0.1 - james [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200 123 "http://example.com/home" "Mozilla/5.0 (X11; Linux x86_64)"`
+
+		r, err := p.ParseLine(line)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, "127.0.0.1", r.RemoteHost)
+		assert.Equal(t, "/report", r.URI)
+		assert.Equal(t, 200, r.StatusCode)
+		assert.Equal(t, "http://example.com/home", r.Referer)
+		assert.Equal(t, "Mozilla/5.0 (X11; Linux x86_64)", r.UserAgent)
+		assert.Empty(t, r.Fields)
+	})
+
+	t.Run("parse escaped quotes", func(t *testing.T) {
+		line := `127.0.0.1 - - [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200 123 "-" "curl \"quoted\" \\ agent"`
+
+		r, err := p.ParseLine(line)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		assert.Equal(t, "-", r.Referer)
+		assert.Equal(t, `curl "quoted" \ agent`, r.UserAgent)
+	})
+
+	t.Run("parse common log format line returns error", func(t *testing.T) {
+		_, err := p.ParseLine("127.0.0.1 - james [09/May/2018:16:00:39 +0000] \"GET /report HTTP/1.0\" 200 123")
+		assert.Error(t, err)
+	})
+}
+
+func TestRequestSection(t *testing.T) {
+	for uri, section := range map[string]string{
+		"/api/users?id=1":                    "/api",
+		"/":                                  "/",
+		"*":                                  "/",
+		"http://www.example.com:80/api/user": "/api",
+		"https://www.example.com":            "/",
+		"/redirect/http://example.com/x":     "/redirect",
+	} {
+		r := &Request{URI: uri}
+		assert.Equal(t, section, r.Section(), uri)
+	}
+}
+
+// duration returns a pointer to d, to compare with Request.Duration
+func duration(d time.Duration) *time.Duration {
+	return &d
+}
+
+func TestRecordDuration(t *testing.T) {
+	tests := []struct {
+		name     string
+		fields   map[string]interface{}
+		expected *time.Duration
+	}{
+		{"no duration field", map[string]interface{}{"host": "example.com"}, nil},
+		{"microseconds", map[string]interface{}{"duration_us": int64(1532)}, duration(1532 * time.Microsecond)},
+		{"seconds", map[string]interface{}{"request_time": 0.153}, duration(153 * time.Millisecond)},
+		{"numeric string", map[string]interface{}{"duration_ms": "12"}, duration(12 * time.Millisecond)},
+		{"duration string", map[string]interface{}{"duration": "1m30s"}, duration(90 * time.Second)},
+		{"duration value", map[string]interface{}{"service": 18 * time.Millisecond}, duration(18 * time.Millisecond)},
+		{"numeric value without unit", map[string]interface{}{"service": int64(18)}, nil},
+		{"numeric duration without unit", map[string]interface{}{"duration": 0.5}, nil},
+		{"negative value", map[string]interface{}{"total_time_ms": int64(-1)}, nil},
+		{"invalid value", map[string]interface{}{"duration_ms": "fast"}, nil},
+		{
+			"most precise field",
+			map[string]interface{}{"duration_s": int64(0), "duration_us": int64(1532)},
+			duration(1532 * time.Microsecond),
+		},
+		{
+			"next field after a negative value",
+			map[string]interface{}{"target_processing_time": -1.0, "duration_s": int64(2)},
+			duration(2 * time.Second),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := NewRecord()
+			r.Fields = test.fields
+			r.setDuration(0)
+			assert.Equal(t, test.expected, r.Duration)
+		})
+	}
+
+	t.Run("numeric duration in the unit of the format", func(t *testing.T) {
+		r := NewRecord()
+		r.Fields = map[string]interface{}{"duration": int64(18)}
+		r.setDuration(time.Millisecond)
+		assert.Equal(t, duration(18*time.Millisecond), r.Duration)
+	})
+
+	t.Run("common log format has no duration", func(t *testing.T) {
+		r, err := NewParser().ParseLine("127.0.0.1 - james [09/May/2018:16:00:39 +0000] \"GET /report HTTP/1.0\" 200 123")
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Nil(t, r.Duration)
+	})
+}
@@ -0,0 +1,65 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Options are the format-specific settings of a Parser (e.g. the field mapping of a JSON parser)
+type Options map[string]string
+
+// Factory creates a Parser configured with the given Options
+type Factory func(options Options) (Parser, error)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a log format available by name to New. Formats are typically registered
+// from the init function of the package implementing them, so that importing the package
+// is enough to make the format available. Register panics if the name is already taken.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if factory == nil {
+		panic("parser: Register factory is nil")
+	}
+	if _, ok := registry[name]; ok {
+		panic("parser: Register called twice for format " + name)
+	}
+	registry[name] = factory
+}
+
+// New returns a Parser for the named log format, configured with the given Options
+func New(name string, options Options) (Parser, error) {
+	registryMu.RLock()
+	factory, ok := registry[name]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown log format %q (available: %s)", name, strings.Join(Formats(), ", "))
+	}
+
+	p, err := factory(options)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	return p, nil
+}
+
+// Formats returns the sorted names of the registered log formats
+func Formats() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
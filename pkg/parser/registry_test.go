@@ -0,0 +1,56 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	t.Run("common format is registered", func(t *testing.T) {
+		assert.Contains(t, Formats(), "common")
+
+		p, err := New("common", nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		r, err := p.ParseLine("127.0.0.1 - james [09/May/2018:16:00:39 +0000] \"GET /report HTTP/1.0\" 200 123")
+		assert.NoError(t, err)
+		assert.Equal(t, "/report", r.URI)
+	})
+
+	t.Run("registered formats are created with their options", func(t *testing.T) {
+		var got Options
+		Register("test-options", func(options Options) (Parser, error) {
+			got = options
+			return NewParser(), nil
+		})
+
+		_, err := New("test-options", Options{"key": "value"})
+		assert.NoError(t, err)
+		assert.Equal(t, Options{"key": "value"}, got)
+	})
+
+	t.Run("factory errors are prefixed with the format", func(t *testing.T) {
+		Register("test-error", func(Options) (Parser, error) {
+			return nil, fmt.Errorf("missing option")
+		})
+
+		_, err := New("test-error", nil)
+		assert.EqualError(t, err, "test-error: missing option")
+	})
+
+	t.Run("unknown format returns error", func(t *testing.T) {
+		_, err := New("unknown", nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("registering a format twice panics", func(t *testing.T) {
+		assert.Panics(t, func() {
+			Register("common", func(Options) (Parser, error) {
+				return NewParser(), nil
+			})
+		})
+	})
+}
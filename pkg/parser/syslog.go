@@ -0,0 +1,269 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// syslogFacilities are the names of the syslog facilities, by code
+var syslogFacilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news", "uucp", "cron", "authpriv",
+	"ftp", "ntp", "security", "console", "solaris-cron", "local0", "local1", "local2", "local3",
+	"local4", "local5", "local6", "local7",
+}
+
+// syslogSeverities are the names of the syslog severities, by code
+var syslogSeverities = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// rfc3164TimeLayout is the time.Parse() layout of RFC 3164 timestamps, which have no year
+const rfc3164TimeLayout = "Jan _2 15:04:05"
+
+// syslogInnerPrefix prefixes the options of the syslog format that are passed to the inner format
+const syslogInnerPrefix = "inner."
+
+func init() {
+	Register("syslog", func(options Options) (Parser, error) {
+		name := options["inner"]
+		if name == "" {
+			name = "common"
+		}
+
+		innerOptions := Options{}
+		for key, value := range options {
+			if strings.HasPrefix(key, syslogInnerPrefix) {
+				innerOptions[strings.TrimPrefix(key, syslogInnerPrefix)] = value
+			}
+		}
+
+		inner, err := New(name, innerOptions)
+		if err != nil {
+			return nil, err
+		}
+		return NewSyslogParser(inner), nil
+	})
+}
+
+// SyslogParser is a Parser for log lines wrapped in an RFC 3164 (BSD) or RFC 5424 syslog header,
+// e.g. `<134>May  9 16:00:39 lb01 nginx[1234]: 127.0.0.1 - - [09/May/2018:16:00:39 +0000] ...`.
+// The message is parsed by an inner Parser, and the header populates the syslog_priority,
+// syslog_facility, syslog_severity, syslog_hostname, syslog_app_name, syslog_procid and
+// syslog_msgid Fields. RFC 5424 structured data params are named after their element, e.g.
+// `[origin@123 ip="10.0.0.1"]` populates `sd_origin_123_ip`. The priority is optional, as
+// syslog daemons usually leave it out of the files they write.
+type SyslogParser struct {
+	inner Parser
+	// now returns the current time, which provides the year of RFC 3164 timestamps
+	now func() time.Time
+}
+
+// NewSyslogParser returns a new SyslogParser parsing messages with inner
+func NewSyslogParser(inner Parser) *SyslogParser {
+	return &SyslogParser{inner: inner, now: time.Now}
+}
+
+// syslogHeader is the header of a syslog message
+type syslogHeader struct {
+	fields    map[string]interface{}
+	timestamp *time.Time
+}
+
+// ParseLine parses a syslog line, and its message with the inner Parser. The timestamp of the
+// message takes precedence over the timestamp of the header.
+func (p *SyslogParser) ParseLine(line string) (*Record, error) {
+	header := syslogHeader{fields: make(map[string]interface{})}
+	msg, err := p.parseHeader(line, &header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse syslog header: %s", err)
+	}
+
+	r, err := p.inner.ParseLine(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Timestamp == nil {
+		r.Timestamp = header.timestamp
+	}
+	for name, value := range header.fields {
+		if _, ok := r.Fields[name]; !ok {
+			r.Fields[name] = value
+		}
+	}
+	return r, nil
+}
+
+// parseHeader reads the header of a syslog line, and returns its message
+func (p *SyslogParser) parseHeader(line string, h *syslogHeader) (string, error) {
+	if strings.HasPrefix(line, "<") {
+		end := strings.IndexByte(line, '>')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated priority")
+		}
+		pri, err := strconv.Atoi(line[1:end])
+		if err != nil || pri < 0 || pri >= len(syslogFacilities)*8 {
+			return "", fmt.Errorf("invalid priority %q", line[1:end])
+		}
+		h.fields["syslog_priority"] = int64(pri)
+		h.fields["syslog_facility"] = syslogFacilities[pri/8]
+		h.fields["syslog_severity"] = syslogSeverities[pri%8]
+		line = line[end+1:]
+
+		// RFC 5424 messages have a version following the priority, e.g. `<165>1 2003-10-11T...`
+		if i := strings.IndexByte(line, ' '); i > 0 && isDigits(line[:i]) {
+			return parseRFC5424(line[i+1:], h)
+		}
+	}
+	return p.parseRFC3164(line, h)
+}
+
+// parseRFC3164 reads the timestamp, hostname and tag (e.g. `nginx[1234]:`) of an RFC 3164 header.
+// The hostname and tag may be missing, and RFC 3339 timestamps are accepted, as written by rsyslog.
+// Without a tag, the hostname cannot be told apart from the message, which is then kept whole.
+func (p *SyslogParser) parseRFC3164(line string, h *syslogHeader) (string, error) {
+	var ts time.Time
+	if len(line) > len(rfc3164TimeLayout) && line[len(rfc3164TimeLayout)] == ' ' {
+		t, err := time.ParseInLocation(rfc3164TimeLayout, line[:len(rfc3164TimeLayout)], time.Local)
+		if err == nil {
+			ts = p.withYear(t)
+			line = line[len(rfc3164TimeLayout)+1:]
+		}
+	}
+	if ts.IsZero() {
+		token, rest := nextToken(line)
+		t, err := time.Parse(time.RFC3339Nano, token)
+		if err != nil {
+			return "", fmt.Errorf("invalid timestamp %q", token)
+		}
+		ts = t
+		line = rest
+	}
+	h.timestamp = &ts
+
+	// the hostname is only told apart from the first word of the message by the tag following it
+	token, rest := nextToken(line)
+	if !strings.HasSuffix(token, ":") {
+		next, afterNext := nextToken(rest)
+		if !strings.HasSuffix(next, ":") {
+			// the tag is optional
+			return line, nil
+		}
+		h.fields["syslog_hostname"] = token
+		token, rest = next, afterNext
+	}
+
+	tag := strings.TrimSuffix(token, ":")
+	if i := strings.IndexByte(tag, '['); i > 0 && strings.HasSuffix(tag, "]") {
+		h.fields["syslog_procid"] = tag[i+1 : len(tag)-1]
+		tag = tag[:i]
+	}
+	h.fields["syslog_app_name"] = tag
+	return rest, nil
+}
+
+// withYear sets the year of an RFC 3164 timestamp: the current year, or the previous year for
+// timestamps in the future (e.g. December logs read in January).
+func (p *SyslogParser) withYear(t time.Time) time.Time {
+	now := p.now()
+	t = t.AddDate(now.Year(), 0, 0)
+	if t.After(now.Add(24 * time.Hour)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t
+}
+
+// parseRFC5424 reads the header fields following the version of an RFC 5424 header
+func parseRFC5424(line string, h *syslogHeader) (string, error) {
+	names := []string{"", "syslog_hostname", "syslog_app_name", "syslog_procid", "syslog_msgid"}
+	for _, name := range names {
+		var token string
+		token, line = nextToken(line)
+		if token == "" {
+			return "", fmt.Errorf("missing header fields")
+		}
+		if token == missingValue {
+			continue
+		}
+
+		if name == "" {
+			ts, err := time.Parse(time.RFC3339Nano, token)
+			if err != nil {
+				return "", fmt.Errorf("invalid timestamp %q", token)
+			}
+			h.timestamp = &ts
+			continue
+		}
+		h.fields[name] = token
+	}
+
+	msg, err := parseStructuredData(line, h)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(msg, "\ufeff"), nil
+}
+
+// parseStructuredData reads the structured data of an RFC 5424 header (e.g. `[id@123 a="1"]` or
+// `-`), and returns the message that follows it
+func parseStructuredData(line string, h *syslogHeader) (string, error) {
+	if strings.HasPrefix(line, missingValue) {
+		return strings.TrimPrefix(strings.TrimPrefix(line, missingValue), " "), nil
+	}
+
+	for strings.HasPrefix(line, "[") {
+		end := strings.IndexAny(line, " ]")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated structured data")
+		}
+		id := line[1:end]
+		line = strings.TrimLeft(line[end:], " ")
+
+		for !strings.HasPrefix(line, "]") {
+			eq := strings.Index(line, `="`)
+			if eq < 0 {
+				return "", fmt.Errorf("invalid structured data element %q", id)
+			}
+			name := line[:eq]
+
+			var value strings.Builder
+			i := eq + 2
+			for ; i < len(line) && line[i] != '"'; i++ {
+				if line[i] == '\\' && i+1 < len(line) && strings.IndexByte(`"\]`, line[i+1]) >= 0 {
+					i++
+				}
+				value.WriteByte(line[i])
+			}
+			if i >= len(line) {
+				return "", fmt.Errorf("unterminated structured data element %q", id)
+			}
+			h.fields[headerField("sd_", id+"_"+name)] = value.String()
+			line = strings.TrimLeft(line[i+1:], " ")
+		}
+		line = line[1:]
+	}
+
+	if line != "" && !strings.HasPrefix(line, " ") {
+		return "", fmt.Errorf("invalid structured data")
+	}
+	return strings.TrimPrefix(line, " "), nil
+}
+
+// nextToken returns the text up to the next space, and the text following it
+func nextToken(s string) (string, string) {
+	i := strings.IndexByte(s, ' ')
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i+1:]
+}
+
+// isDigits returns true if s is made of digits only
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,154 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyslogParser(t *testing.T) {
+	now := time.Date(2018, 5, 10, 0, 0, 0, 0, time.Local)
+	p := NewSyslogParser(NewParser())
+	p.now = func() time.Time { return now }
+
+	t.Run("parse rfc 3164 line", func(t *testing.T) {
+		line := `<134>May  9 16:00:39 lb01 nginx[1234]: 127.0.0.1 - james [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200 123`
+
+		r, err := p.ParseLine(line)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, time.Date(2018, 5, 9, 16, 0, 39, 0, time.UTC), r.Timestamp.UTC())
+		assert.Equal(t, "127.0.0.1", r.RemoteHost)
+		assert.Equal(t, "james", r.AuthUser)
+		assert.Equal(t, "/report", r.URI)
+		assert.Equal(t, 200, r.StatusCode)
+		assert.Equal(t, map[string]interface{}{
+			"syslog_priority": int64(134),
+			"syslog_facility": "local0",
+			"syslog_severity": "info",
+			"syslog_hostname": "lb01",
+			"syslog_app_name": "nginx",
+			"syslog_procid":   "1234",
+		}, r.Fields)
+	})
+
+	t.Run("parse rfc 3164 line written to a file", func(t *testing.T) {
+		line := `2018-05-09T16:00:39.123+00:00 lb01 nginx: 127.0.0.1 - - [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200 123`
+
+		r, err := p.ParseLine(line)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "/report", r.URI)
+		assert.Equal(t, map[string]interface{}{
+			"syslog_hostname": "lb01",
+			"syslog_app_name": "nginx",
+		}, r.Fields)
+	})
+
+	t.Run("header timestamp is used if the message has none", func(t *testing.T) {
+		p := NewSyslogParser(NewHAProxyParser())
+		p.now = func() time.Time { return now }
+
+		r, err := p.ParseLine(`Dec 31 23:59:59 haproxy[14389]: 10.0.1.2:33317 [31/Dec/2017:23:59:59.655] http-in static/srv1 10/0/30/69/109 200 2750 - - ---- 1/1/1/1/0 0/0 "GET /index.html HTTP/1.1"`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, 2017, r.Timestamp.Year())
+		assert.Equal(t, "haproxy", r.Fields["syslog_app_name"])
+		assert.NotContains(t, r.Fields, "syslog_hostname")
+
+		p = NewSyslogParser(NewW3CParser([]string{"cs-uri-stem"}))
+		p.now = func() time.Time { return now }
+
+		r, err = p.ParseLine(`<13>May  9 16:00:39 iis01 w3svc: /report`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, time.Date(2018, 5, 9, 16, 0, 39, 0, time.Local), *r.Timestamp)
+		assert.Equal(t, "/report", r.URI)
+		assert.Equal(t, "iis01", r.Fields["syslog_hostname"])
+	})
+
+	t.Run("message is kept whole without hostname and tag", func(t *testing.T) {
+		r, err := p.ParseLine(`<13>May  9 16:00:39 127.0.0.1 - james [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200 123`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "127.0.0.1", r.RemoteHost)
+		assert.Equal(t, "james", r.AuthUser)
+		assert.Equal(t, "/report", r.URI)
+		assert.NotContains(t, r.Fields, "syslog_hostname")
+		assert.NotContains(t, r.Fields, "syslog_app_name")
+	})
+
+	t.Run("parse rfc 5424 line", func(t *testing.T) {
+		line := `<165>1 2018-05-09T16:00:39.003Z lb01.example.com nginx 1234 ACCESS [origin@32473 ip="10.0.0.1" note="a \"quoted\" \] value"][meta@32473] ` +
+			"\ufeff" + `127.0.0.1 - - [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 404 0`
+
+		r, err := p.ParseLine(line)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "/report", r.URI)
+		assert.Equal(t, 404, r.StatusCode)
+		assert.Equal(t, map[string]interface{}{
+			"syslog_priority":      int64(165),
+			"syslog_facility":      "local4",
+			"syslog_severity":      "notice",
+			"syslog_hostname":      "lb01.example.com",
+			"syslog_app_name":      "nginx",
+			"syslog_procid":        "1234",
+			"syslog_msgid":         "ACCESS",
+			"sd_origin_32473_ip":   "10.0.0.1",
+			"sd_origin_32473_note": `a "quoted" ] value`,
+		}, r.Fields)
+	})
+
+	t.Run("parse rfc 5424 line without structured data", func(t *testing.T) {
+		line := `<14>1 2018-05-09T16:00:39Z lb01 - - - - 127.0.0.1 - - [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200 123`
+
+		r, err := p.ParseLine(line)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "/report", r.URI)
+		assert.Equal(t, "lb01", r.Fields["syslog_hostname"])
+		assert.NotContains(t, r.Fields, "syslog_app_name")
+	})
+
+	t.Run("inner format and options", func(t *testing.T) {
+		p, err := New("syslog", Options{
+			"inner":            "nginx",
+			"inner.log_format": `$remote_addr "$request" $status $request_time`,
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		r, err := p.ParseLine(`<134>1 2018-05-09T16:00:39Z lb01 nginx - - - 127.0.0.1 "GET /report HTTP/1.1" 200 0.015`)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "/report", r.URI)
+		assert.Equal(t, 0.015, r.Fields["request_time"])
+		assert.Equal(t, time.Date(2018, 5, 9, 16, 0, 39, 0, time.UTC), *r.Timestamp)
+
+		_, err = New("syslog", Options{"inner": "unknown"})
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid lines return error", func(t *testing.T) {
+		for _, line := range []string{
+			`127.0.0.1 - - [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200 123`,
+			`<999>May  9 16:00:39 lb01 nginx: 127.0.0.1 - - [09/May/2018:16:00:39 +0000] "GET / HTTP/1.0" 200 1`,
+			`<134>May  9 16:00:39 lb01 nginx: not an access log`,
+			`<134>1 2018-05-09T16:00:39Z lb01 nginx - - [origin ip="10.0.0.1" 127.0.0.1`,
+		} {
+			_, err := p.ParseLine(line)
+			assert.Error(t, err, line)
+		}
+	})
+}
@@ -0,0 +1,153 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// w3cColumns maps the W3C extended log fields describing the request onto columns
+var w3cColumns = map[string]column{
+	"date":                {field: "datetime"},
+	"time":                {field: "datetime"},
+	"c-ip":                {field: "remote_host"},
+	"cs-username":         {field: "user"},
+	"cs-method":           {field: "request_method"},
+	"cs-uri-stem":         {field: "request_uri"},
+	"cs-protocol-version": {field: "http_version"},
+	"sc-status":           {field: "status_code"},
+	"sc-bytes":            {field: "response_size"},
+	"cs(referer)":         {field: "referer"},
+	"cs(user-agent)":      {field: "user_agent"},
+	"cs-bytes":            {field: "cs_bytes", fieldType: IntField},
+	"time-taken":          {field: "time_taken", fieldType: FloatField},
+	"time-to-first-byte":  {field: "time_to_first_byte", fieldType: FloatField},
+	"c-port":              {field: "c_port", fieldType: IntField},
+	"s-port":              {field: "s_port", fieldType: IntField},
+	"sc-content-len":      {field: "sc_content_len", fieldType: IntField},
+	"sc-range-start":      {field: "sc_range_start", fieldType: IntField},
+	"sc-range-end":        {field: "sc_range_end", fieldType: IntField},
+}
+
+// w3cTimeLayout is the time.Parse() layout of the joined date and time fields of W3C logs
+const w3cTimeLayout = "2006-01-02 15:04:05"
+
+func init() {
+	Register("w3c", func(options Options) (Parser, error) {
+		return NewW3CParser(strings.Fields(options["fields"])), nil
+	})
+}
+
+// W3CParser is a Parser for W3C extended logs (e.g. IIS or CDN logs), whose columns are declared
+// by `#Fields` directives (e.g. `#Fields: date time c-ip cs-method cs-uri-stem sc-status`). The
+// columns are rebuilt on each `#Fields` directive, as the layout may change within a file, and
+// directives return ErrSkipLine. Fields describing the request populate the Request, the others
+// populate Fields, named after the W3C field, e.g. `s-sitename` is kept as `s_sitename` and
+// `cs(Host)` as `cs_host`. Values are separated by tabs or spaces. The time-taken field, in
+// milliseconds, sets the Duration of the Request.
+//
+// A W3CParser holds the state of the log it parses, and must not be shared by several logs.
+type W3CParser struct {
+	// columns parses the values of data lines, according to the last `#Fields` directive
+	columns *ColumnParser
+	// unescape is true if the values are URL-encoded
+	unescape bool
+	// requiredField, if set, is a field that `#Fields` directives must declare (e.g.
+	// x-edge-location for CloudFront logs), so that other W3C logs are rejected
+	requiredField string
+	// timeTakenUnit is the unit of the time-taken field, which sets the Duration of the Request:
+	// milliseconds for IIS, seconds for CloudFront
+	timeTakenUnit time.Duration
+}
+
+// NewW3CParser returns a new W3CParser reading the given fields (e.g. `date`, `time`, `c-ip`)
+// until a `#Fields` directive is read. Without fields, data lines preceding the first `#Fields`
+// directive return an error.
+func NewW3CParser(fields []string) *W3CParser {
+	p := &W3CParser{timeTakenUnit: time.Millisecond}
+	p.setFields(fields)
+	return p
+}
+
+// ParseLine parses a data line, or reads a directive and returns ErrSkipLine
+func (p *W3CParser) ParseLine(line string) (*Record, error) {
+	if strings.HasPrefix(line, "#") {
+		if fields := strings.TrimPrefix(line, "#Fields:"); fields != line {
+			names := strings.Fields(fields)
+			if p.requiredField != "" && !containsString(names, p.requiredField) {
+				return nil, fmt.Errorf("failed to parse #Fields directive: missing %s field", p.requiredField)
+			}
+			p.setFields(names)
+		}
+		return nil, ErrSkipLine
+	}
+
+	if p.columns == nil {
+		return nil, fmt.Errorf("failed to parse log line: no #Fields directive")
+	}
+
+	r, err := p.columns.ParseLine(line)
+	if err != nil {
+		return nil, err
+	}
+	if d, ok := durationValue(r.Fields["time_taken"], p.timeTakenUnit); ok && r.Duration == nil {
+		r.Duration = &d
+	}
+	return r, nil
+}
+
+// setFields sets the columns of the data lines
+func (p *W3CParser) setFields(fields []string) {
+	if len(fields) == 0 {
+		p.columns = nil
+		return
+	}
+
+	hasDate := false
+	for _, name := range fields {
+		hasDate = hasDate || strings.EqualFold(name, "date")
+	}
+
+	columns := make([]column, len(fields))
+	for i, name := range fields {
+		columns[i] = w3cColumn(name)
+		if !hasDate && strings.EqualFold(name, "time") {
+			// without a date, the time does not set the timestamp
+			columns[i] = column{field: "time"}
+		}
+	}
+
+	p.columns = &ColumnParser{
+		columns:    columns,
+		required:   len(columns),
+		split:      splitW3C,
+		timeLayout: w3cTimeLayout,
+		unescape:   p.unescape,
+	}
+}
+
+// w3cColumn returns the column for a W3C extended log field
+func w3cColumn(name string) column {
+	if c, ok := w3cColumns[strings.ToLower(name)]; ok {
+		return c
+	}
+	return column{field: strings.Trim(headerField("", name), "_")}
+}
+
+// splitW3C splits a line into tab-separated values, or space-separated values which may be quoted
+func splitW3C(line string) ([]string, error) {
+	if strings.Contains(line, "\t") {
+		return splitTabs(line)
+	}
+	return splitQuoted(line)
+}
+
+// containsString returns true if s is one of values
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
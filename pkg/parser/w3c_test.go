@@ -0,0 +1,102 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestW3CParser(t *testing.T) {
+	t.Run("parse iis log", func(t *testing.T) {
+		p, err := New("w3c", nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		for _, directive := range []string{
+			"#Software: Microsoft Internet Information Services 10.0",
+			"#Version: 1.0",
+			"#Date: 2019-12-04 21:02:31",
+			"#Fields: date time s-ip cs-method cs-uri-stem cs-uri-query s-port cs-username c-ip cs(User-Agent) cs(Referer) sc-status sc-substatus sc-win32-status time-taken",
+		} {
+			_, err := p.ParseLine(directive)
+			assert.Equal(t, ErrSkipLine, err, directive)
+		}
+
+		r, err := p.ParseLine("2019-12-04 21:02:31 10.0.0.4 GET /api/users id=1 443 - 192.0.2.100 Mozilla/5.0+(Windows+NT+10.0) - 200 0 0 15")
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, time.Date(2019, 12, 4, 21, 2, 31, 0, time.UTC), *r.Timestamp)
+		assert.Equal(t, "192.0.2.100", r.RemoteHost)
+		assert.Equal(t, "GET", r.Method)
+		assert.Equal(t, "/api", r.Section())
+		assert.Equal(t, 200, r.StatusCode)
+		assert.Equal(t, "Mozilla/5.0+(Windows+NT+10.0)", r.UserAgent)
+		assert.Equal(t, map[string]interface{}{
+			"s_ip":            "10.0.0.4",
+			"cs_uri_query":    "id=1",
+			"s_port":          int64(443),
+			"sc_substatus":    "0",
+			"sc_win32_status": "0",
+			"time_taken":      15.0,
+		}, r.Fields)
+		assert.Equal(t, duration(15*time.Millisecond), r.Duration)
+	})
+
+	t.Run("fields directive changes columns", func(t *testing.T) {
+		p := NewW3CParser([]string{"date", "time", "cs-uri-stem"})
+
+		r, err := p.ParseLine("2019-12-04 21:02:31 /home")
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "/home", r.URI)
+
+		_, err = p.ParseLine("#Fields: date time cs-method cs-uri-stem sc-status")
+		assert.Equal(t, ErrSkipLine, err)
+
+		r, err = p.ParseLine("2019-12-04 21:02:32 POST /report 201")
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, "POST", r.Method)
+		assert.Equal(t, "/report", r.URI)
+		assert.Equal(t, 201, r.StatusCode)
+
+		_, err = p.ParseLine("2019-12-04 21:02:32 /home")
+		assert.Error(t, err)
+	})
+
+	t.Run("fields option sets default columns", func(t *testing.T) {
+		p, err := New("w3c", Options{"fields": "date time cs-uri-stem sc-status"})
+		if !assert.NoError(t, err) {
+			return
+		}
+
+		r, err := p.ParseLine("2019-12-04\t21:02:31\t/home\t404")
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, 404, r.StatusCode)
+	})
+
+	t.Run("time without date", func(t *testing.T) {
+		p := NewW3CParser([]string{"time", "cs-uri-stem"})
+
+		r, err := p.ParseLine("21:02:31 /home")
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Nil(t, r.Timestamp)
+		assert.Equal(t, "21:02:31", r.Fields["time"])
+	})
+
+	t.Run("data before fields directive returns error", func(t *testing.T) {
+		p := NewW3CParser(nil)
+
+		_, err := p.ParseLine("2019-12-04 21:02:31 /home")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,139 @@
+package tail
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// defaultCheckpointInterval is the interval at which a Tail records its position when
+// Config.CheckpointInterval is not set
+const defaultCheckpointInterval = 5 * time.Second
+
+// Position is the read position within a file. The device and inode identify the file
+// the offset refers to, so that a rotated file can be told apart from its replacement.
+type Position struct {
+	Device uint64 `json:"device"`
+	Inode  uint64 `json:"inode"`
+	Offset int64  `json:"offset"`
+}
+
+// Checkpoints persists the read positions of followed files, so that reading can resume
+// where it left off after a restart. Positions are recorded by file path, which allows
+// a single checkpoint file to be shared by several Tails.
+type Checkpoints struct {
+	path string
+
+	mu        sync.Mutex
+	positions map[string]Position
+	dirty     bool
+}
+
+// OpenCheckpoints loads the checkpoint file at the given path. A missing file is not an
+// error; it is created on the first Flush.
+func OpenCheckpoints(path string) (*Checkpoints, error) {
+	c := &Checkpoints{
+		path:      path,
+		positions: make(map[string]Position),
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, &c.positions); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// key returns the key under which the position of a file is recorded. Paths are made absolute
+// so that the checkpoint does not depend on the working directory.
+func key(file string) string {
+	if abs, err := filepath.Abs(file); err == nil {
+		return abs
+	}
+	return file
+}
+
+// Get returns the recorded position for a file
+func (c *Checkpoints) Get(file string) (Position, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pos, ok := c.positions[key(file)]
+	return pos, ok
+}
+
+// Set records the position for a file. The position is persisted on the next Flush.
+func (c *Checkpoints) Set(file string, pos Position) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	k := key(file)
+	if c.positions[k] != pos {
+		c.positions[k] = pos
+		c.dirty = true
+	}
+}
+
+// Flush writes the recorded positions to the checkpoint file, if they changed since the
+// last Flush. The file is replaced atomically, so a crash never leaves it half written.
+func (c *Checkpoints) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dirty {
+		return nil
+	}
+
+	b, err := json.MarshalIndent(c.positions, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(c.path), filepath.Base(c.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return err
+	}
+
+	c.dirty = false
+	return nil
+}
+
+// findFile returns the path of the file in dir with the given device and inode, e.g. a log
+// file that was renamed by logrotate. It returns "" if there is no such file.
+func findFile(dir string, device, inode uint64) (string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for _, info := range entries {
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		if dev, ino, ok := fileID(info); ok && dev == device && ino == inode {
+			return filepath.Join(dir, info.Name()), nil
+		}
+	}
+
+	return "", nil
+}
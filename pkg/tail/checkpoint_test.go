@@ -0,0 +1,126 @@
+package tail
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckpoints(t *testing.T) {
+	path, cleanup := tempFile(t, "")
+	defer cleanup()
+	cpPath := filepath.Join(filepath.Dir(path), "checkpoint.json")
+
+	t.Run("missing checkpoint file is empty", func(t *testing.T) {
+		cp, err := OpenCheckpoints(cpPath)
+		assert.NoError(t, err)
+		_, ok := cp.Get(path)
+		assert.False(t, ok)
+	})
+
+	t.Run("positions are persisted on flush", func(t *testing.T) {
+		cp, err := OpenCheckpoints(cpPath)
+		assert.NoError(t, err)
+		cp.Set(path, Position{Device: 1, Inode: 2, Offset: 3})
+		assert.NoError(t, cp.Flush())
+
+		cp, err = OpenCheckpoints(cpPath)
+		assert.NoError(t, err)
+		pos, ok := cp.Get(path)
+		assert.True(t, ok)
+		assert.Equal(t, Position{Device: 1, Inode: 2, Offset: 3}, pos)
+	})
+}
+
+func TestTailCheckpoint(t *testing.T) {
+	path, cleanup := tempFile(t, "one\ntwo\n")
+	defer cleanup()
+	cpPath := filepath.Join(filepath.Dir(path), "checkpoint.json")
+
+	// readAndStop reads n lines from the file, resuming from the checkpoint, then stops
+	readAndStop := func(t *testing.T, n int) []string {
+		cp, err := OpenCheckpoints(cpPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tl, err := TailFile(path, &Config{FromStart: true, Checkpoints: cp})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lines := []string{}
+		for i := 0; i < n; i++ {
+			lines = append(lines, nextLine(t, tl).Text)
+		}
+		tl.Stop()
+		return lines
+	}
+
+	t.Run("first run reads from the start", func(t *testing.T) {
+		assert.Equal(t, []string{"one", "two"}, readAndStop(t, 2))
+	})
+
+	t.Run("restart resumes after the last line read", func(t *testing.T) {
+		appendLine(t, path, "three")
+		assert.Equal(t, []string{"three"}, readAndStop(t, 1))
+	})
+
+	t.Run("restart after rotation drains the rotated file first", func(t *testing.T) {
+		appendLine(t, path, "four")
+		rotated := path + ".1"
+		if err := os.Rename(path, rotated); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte("five\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, []string{"four", "five"}, readAndStop(t, 2))
+	})
+
+	t.Run("restart while draining the rotated file resumes in the rotated file", func(t *testing.T) {
+		appendLine(t, path, "six")
+		appendLine(t, path, "seven")
+		rotated := path + ".2"
+		if err := os.Rename(path, rotated); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte("eight\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, []string{"six"}, readAndStop(t, 1))
+		assert.Equal(t, []string{"seven", "eight"}, readAndStop(t, 2))
+	})
+
+	t.Run("position is recorded while catching up", func(t *testing.T) {
+		catchUp, cleanup := tempFile(t, "one\ntwo\nthree\n")
+		defer cleanup()
+		cp, err := OpenCheckpoints(filepath.Join(filepath.Dir(catchUp), "catch-up.json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tl, err := TailFile(catchUp, &Config{FromStart: true, Checkpoints: cp, CheckpointInterval: time.Nanosecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tl.Stop()
+
+		nextLine(t, tl)
+		// the position after the first line is recorded before the second line is delivered
+		nextLine(t, tl)
+		recorded, err := OpenCheckpoints(cp.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pos, ok := recorded.Get(catchUp)
+		assert.True(t, ok)
+		assert.True(t, pos.Offset >= int64(len("one\n")), "recorded offset %d", pos.Offset)
+	})
+}
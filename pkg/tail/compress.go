@@ -0,0 +1,143 @@
+package tail
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// compression is a compression format that can be read transparently
+type compression string
+
+const (
+	uncompressed compression = ""
+	gzipped      compression = "gzip"
+	bzipped      compression = "bzip2"
+	zstandard    compression = "zstd"
+)
+
+// magicNumbers maps each compression format to the bytes its streams start with
+var magicNumbers = map[compression][]byte{
+	gzipped:   {0x1f, 0x8b},
+	bzipped:   []byte("BZh"),
+	zstandard: {0x28, 0xb5, 0x2f, 0xfd},
+}
+
+// extensions maps file extensions to the compression format they conventionally denote
+var extensions = map[string]compression{
+	".gz":  gzipped,
+	".bz2": bzipped,
+	".zst": zstandard,
+}
+
+// detectCompression identifies the compression format of a stream from the extension of its
+// name, or from its first bytes if the extension is not that of a compression format (e.g.
+// a rotated `access.log.1`, or stdin). A known extension takes precedence, so that a plain
+// log starting with the magic bytes of a format (e.g. "BZh") but named as such is read as is.
+func detectCompression(header []byte, name string) compression {
+	if format, ok := extensions[strings.ToLower(filepath.Ext(name))]; ok {
+		return format
+	}
+
+	for format, magic := range magicNumbers {
+		if bytes.HasPrefix(header, magic) {
+			return format
+		}
+	}
+	return uncompressed
+}
+
+// Decompress returns a reader that transparently decompresses r if it is compressed with
+// gzip, bzip2 or zstd, and r itself (buffered) otherwise. The format is detected from the
+// first bytes of the stream.
+func Decompress(r io.Reader) (io.Reader, error) {
+	return decompress(bufio.NewReader(r), "")
+}
+
+// decompress wraps r with a decompressor for the format detected from the extension of
+// name, or from the first bytes of r.
+func decompress(r *bufio.Reader, name string) (io.Reader, error) {
+	// an error here means the stream is shorter than the header, which leaves it
+	// for the decompressors to report
+	header, _ := r.Peek(4)
+
+	switch detectCompression(header, name) {
+	case gzipped:
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("gzip: %s", err)
+		}
+		return zr, nil
+	case bzipped:
+		return bzip2.NewReader(r), nil
+	case zstandard:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("zstd: %s", err)
+		}
+		return zr.IOReadCloser(), nil
+	default:
+		return r, nil
+	}
+}
+
+// IsCompressed reports whether the file at path is compressed in a format that Open
+// decompresses transparently.
+func IsCompressed(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	header := make([]byte, 4)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+
+	return detectCompression(header[:n], path) != uncompressed, nil
+}
+
+// Open returns a Source for the file at path. Compressed files (gzip, bzip2 or zstd) are
+// not written to anymore, so they are read once with ReadFile, regardless of the start
+// position options. Other files are followed with TailFile.
+func Open(path string, config *Config) (Source, error) {
+	compressed, err := IsCompressed(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if !compressed {
+		return TailFile(path, config)
+	}
+
+	return ReadFile(path)
+}
+
+// ReadFile returns a Reader that reads the file at path from the start and is exhausted at
+// the end of the file. Compressed files are decompressed transparently.
+func ReadFile(path string) (*Reader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := decompress(bufio.NewReader(f), path)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	if c, ok := r.(io.Closer); ok {
+		return newReader(path, r, c, f), nil
+	}
+	return newReader(path, r, f), nil
+}
@@ -0,0 +1,196 @@
+package tail
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+// bzip2Lines is "one\ntwo\n" compressed with bzip2, which the standard library cannot write
+var bzip2Lines = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0xa7, 0x14,
+	0x2b, 0x77, 0x00, 0x00, 0x02, 0xc1, 0x80, 0x00, 0x10, 0x02, 0x01, 0x84,
+	0x80, 0x20, 0x00, 0x21, 0x80, 0x0c, 0x02, 0x38, 0xf5, 0x1b, 0x8b, 0xb9,
+	0x22, 0x9c, 0x28, 0x48, 0x53, 0x8a, 0x15, 0xbb, 0x80,
+}
+
+// gzipLines compresses content with gzip
+func gzipLines(t *testing.T, content string) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dtail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(name string, content []byte) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	t.Run("reads gzip files to the end", func(t *testing.T) {
+		path := write("access.log.2.gz", gzipLines(t, "one\ntwo\n"))
+		src, err := Open(path, &Config{})
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, &Line{Text: "one", Source: path}, <-src.Lines())
+		assert.Equal(t, &Line{Text: "two", Source: path}, <-src.Lines())
+		assert.Empty(t, collect(src))
+		assert.NoError(t, src.Wait())
+	})
+
+	t.Run("reads bzip2 files to the end", func(t *testing.T) {
+		src, err := Open(write("access.log.2.bz2", bzip2Lines), &Config{})
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []string{"one", "two"}, collect(src))
+	})
+
+	t.Run("reads zstd files to the end", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, err := zstd.NewWriter(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte("one\ntwo\n"))
+		w.Close()
+
+		src, err := Open(write("access.log.2.zst", buf.Bytes()), &Config{})
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []string{"one", "two"}, collect(src))
+		assert.NoError(t, src.Wait())
+	})
+
+	t.Run("detects compression from magic bytes without extension", func(t *testing.T) {
+		src, err := Open(write("access", gzipLines(t, "one\n")), &Config{})
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []string{"one"}, collect(src))
+	})
+
+	t.Run("detects compression from magic bytes with another extension", func(t *testing.T) {
+		for _, name := range []string{"access.log.1", "rotated.log", "access.old"} {
+			path := write(name, gzipLines(t, "one\n"))
+			compressed, err := IsCompressed(path)
+			assert.NoError(t, err)
+			assert.True(t, compressed, name)
+
+			src, err := Open(path, &Config{})
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, []string{"one"}, collect(src), name)
+		}
+	})
+
+	t.Run("compression extension takes precedence over magic bytes", func(t *testing.T) {
+		// a zstd stream named as gzip is reported as corrupted rather than decompressed
+		var buf bytes.Buffer
+		w, err := zstd.NewWriter(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte("one\n"))
+		w.Close()
+
+		_, err = Open(write("misnamed.log.gz", buf.Bytes()), &Config{})
+		assert.Error(t, err)
+	})
+
+	t.Run("corrupted files are reported", func(t *testing.T) {
+		_, err := Open(write("corrupted.log.gz", []byte("not gzip\n")), &Config{})
+		assert.Error(t, err)
+	})
+
+	t.Run("read file reads uncompressed files to the end", func(t *testing.T) {
+		r, err := ReadFile(write("access.log.1", []byte("one\ntwo")))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []string{"one", "two"}, collect(r))
+	})
+
+	t.Run("follows uncompressed files", func(t *testing.T) {
+		src, err := Open(write("access.log", nil), &Config{})
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer src.Stop()
+		assert.IsType(t, &Tail{}, src)
+	})
+}
+
+func TestDecompress(t *testing.T) {
+	t.Run("decompresses gzip streams", func(t *testing.T) {
+		r, err := Decompress(bytes.NewReader(gzipLines(t, "one\ntwo\n")))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []string{"one", "two"}, collect(NewReader("stdin", r)))
+	})
+
+	t.Run("passes uncompressed streams through", func(t *testing.T) {
+		r, err := Decompress(strings.NewReader("one\ntwo\n"))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, []string{"one", "two"}, collect(NewReader("stdin", r)))
+	})
+
+	t.Run("empty streams are not compressed", func(t *testing.T) {
+		r, err := Decompress(strings.NewReader(""))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Empty(t, collect(NewReader("stdin", r)))
+	})
+}
+
+func TestTailGlobCompressed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dtail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, name := range []string{"access.log.1.gz", "access.log.2.gz"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), gzipLines(t, name+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m, err := TailGlob([]string{filepath.Join(dir, "*.gz")}, &Config{})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// the Multi is exhausted once every compressed file has been read
+	assert.ElementsMatch(t, []string{"access.log.1.gz", "access.log.2.gz"}, collect(m))
+	assert.NoError(t, m.Wait())
+}
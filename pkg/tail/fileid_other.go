@@ -0,0 +1,12 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package tail
+
+import "os"
+
+// fileID returns the device and inode of a file. They are not available on this platform,
+// so positions are validated using the file size only.
+func fileID(info os.FileInfo) (device, inode uint64, ok bool) {
+	return 0, 0, false
+}
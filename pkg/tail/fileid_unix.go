@@ -0,0 +1,18 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package tail
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileID returns the device and inode of a file
+func fileID(info os.FileInfo) (device, inode uint64, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return uint64(stat.Dev), uint64(stat.Ino), true
+}
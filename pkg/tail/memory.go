@@ -0,0 +1,31 @@
+package tail
+
+// Memory is a Source that delivers a fixed set of lines held in memory and is exhausted
+// once all of them have been consumed. It is mostly useful for testing.
+type Memory struct {
+	pipe
+
+	buf []string
+}
+
+// NewMemory returns a new Memory that delivers the given lines in order.
+func NewMemory(lines ...string) *Memory {
+	m := &Memory{
+		pipe: newPipe("memory"),
+		buf:  lines,
+	}
+
+	go m.readAll()
+
+	return m
+}
+
+func (m *Memory) readAll() {
+	defer m.close()
+
+	for _, line := range m.buf {
+		if !m.send(line) {
+			return
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package tail
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemory(t *testing.T) {
+	t.Run("delivers lines in order", func(t *testing.T) {
+		m := NewMemory("one", "two", "three")
+		assert.Equal(t, []string{"one", "two", "three"}, collect(m))
+		assert.NoError(t, m.Wait())
+	})
+
+	t.Run("stop before consuming closes Lines", func(t *testing.T) {
+		m := NewMemory("one", "two")
+		m.Stop()
+		for range m.Lines() {
+		}
+		assert.NoError(t, m.Wait())
+	})
+}
@@ -0,0 +1,272 @@
+package tail
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// globInterval is the interval at which TailGlob re-evaluates its patterns
+const globInterval = 1 * time.Second
+
+// Multi is a Source that merges the lines of several Sources into a single stream. Each line
+// is labeled with the name its Source was added under, and errors are prefixed with it.
+// A Source delivering an error other than a Notice is stopped, while the others are read on.
+type Multi struct {
+	pipe
+
+	mu      sync.Mutex
+	sources map[string]Source
+	// archives holds the compressed files matched by a glob, which are read only once
+	archives map[string]bool
+
+	// wg tracks the running sources, plus one for as long as sources may still be added
+	wg      sync.WaitGroup
+	holdEnd sync.Once
+}
+
+// NewMulti returns a new, empty, Multi. The Multi is exhausted once every Source added to it
+// is exhausted and Close has been called.
+func NewMulti() *Multi {
+	m := &Multi{
+		pipe:     newPipe(""),
+		sources:  make(map[string]Source),
+		archives: make(map[string]bool),
+	}
+	m.wg.Add(1)
+
+	go func() {
+		m.wg.Wait()
+		m.close()
+	}()
+
+	return m
+}
+
+// Add merges a Source into the Multi under the given name. It returns false, and leaves
+// src untouched, if a Source with the same name is already being read.
+func (m *Multi) Add(name string, src Source) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stopped() {
+		return false
+	}
+	if _, ok := m.sources[name]; ok {
+		return false
+	}
+
+	m.sources[name] = src
+	m.wg.Add(1)
+	go m.forward(name, src)
+
+	return true
+}
+
+// Has reports whether a Source with the given name is being read
+func (m *Multi) Has(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.sources[name]
+	return ok
+}
+
+// Close signals that no more sources will be added, allowing the Multi to be exhausted
+// once its current sources are.
+func (m *Multi) Close() {
+	m.holdEnd.Do(m.wg.Done)
+}
+
+// Stop stops the Multi and waits for every Source it is reading from to stop
+func (m *Multi) Stop() error {
+	// stopping under the lock guarantees that no Source is added afterwards
+	m.mu.Lock()
+	m.pipe.Stop()
+	m.mu.Unlock()
+
+	m.Close()
+	m.wg.Wait()
+	return nil
+}
+
+// stopped reports whether the Multi has been stopped
+func (m *Multi) stopped() bool {
+	select {
+	case <-m.doneCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// forward relays the lines and errors of src until it is exhausted, fails or the Multi is stopped
+func (m *Multi) forward(name string, src Source) {
+	defer m.wg.Done()
+	defer func() {
+		m.mu.Lock()
+		delete(m.sources, name)
+		m.mu.Unlock()
+	}()
+
+	lines, errs := src.Lines(), src.Errors()
+	for lines != nil || errs != nil {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				lines = nil
+				continue
+			}
+			if !m.sendLine(&Line{Text: line.Text, Source: name}) {
+				src.Stop()
+				return
+			}
+
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			if !m.sendError(fmt.Errorf("%s: %w", name, err)) || !IsNotice(err) {
+				src.Stop()
+				return
+			}
+
+		case <-m.doneCh:
+			src.Stop()
+			return
+		}
+	}
+}
+
+// HasMeta reports whether a path contains any of the special characters recognized by filepath.Match
+func HasMeta(path string) bool {
+	return strings.ContainsAny(path, `*?[\`)
+}
+
+// TailGlob follows every file matching the given paths or glob patterns, similar to
+// `tail -f /var/log/nginx/*.log`. Lines are labeled with the path of the file they were
+// read from. Patterns are re-evaluated periodically, so that files created after startup
+// are followed as well, from their beginning: the start position set by config only applies
+// to the files matched at startup. Files are opened with Open, so compressed files are read
+// once to the end; a pattern matching only compressed files is not re-evaluated, so that the
+// Multi is exhausted once they have all been read.
+func TailGlob(patterns []string, config *Config) (*Multi, error) {
+	m := NewMulti()
+
+	var watched []string
+	for _, pattern := range patterns {
+		if !HasMeta(pattern) {
+			src, err := Open(pattern, config)
+			if err != nil {
+				m.Stop()
+				return nil, err
+			}
+			m.Add(pattern, src)
+			continue
+		}
+
+		follow, err := m.glob(pattern, config)
+		if err != nil {
+			m.Stop()
+			return nil, err
+		}
+		if follow {
+			watched = append(watched, pattern)
+		}
+	}
+
+	if len(watched) == 0 {
+		m.Close()
+		return m, nil
+	}
+
+	go m.watchGlobs(watched, config)
+
+	return m, nil
+}
+
+// glob opens any file matching pattern that is not already being read. It returns true if
+// the pattern should be re-evaluated, i.e. it matches no file yet or an uncompressed one.
+func (m *Multi) glob(pattern string, config *Config) (bool, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return false, fmt.Errorf("%s: %s", pattern, err)
+	}
+
+	follow := len(matches) == 0
+	for _, path := range matches {
+		if m.isArchive(path) {
+			continue
+		}
+		compressed, err := IsCompressed(path)
+		if err != nil {
+			return false, err
+		}
+		if !compressed {
+			follow = true
+		}
+		if m.Has(path) {
+			continue
+		}
+
+		src, err := Open(path, config)
+		if err != nil {
+			return false, err
+		}
+		if !m.Add(path, src) {
+			src.Stop()
+			continue
+		}
+		if compressed {
+			m.mu.Lock()
+			m.archives[path] = true
+			m.mu.Unlock()
+		}
+	}
+
+	return follow, nil
+}
+
+// fromStart returns a copy of config that reads files from the beginning
+func fromStart(config *Config) *Config {
+	c := *config
+	c.FromStart = true
+	c.LastLines = 0
+	c.Offset = 0
+	return &c
+}
+
+// isArchive reports whether path is a compressed file that has already been read
+func (m *Multi) isArchive(path string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.archives[path]
+}
+
+// watchGlobs re-evaluates the glob patterns at every globInterval until the Multi is stopped.
+// The files found are read from the beginning, so that the lines written before they were
+// found are not lost.
+func (m *Multi) watchGlobs(patterns []string, config *Config) {
+	defer m.Close()
+
+	config = fromStart(config)
+
+	ticker := time.NewTicker(globInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, pattern := range patterns {
+				if _, err := m.glob(pattern, config); err != nil {
+					if !m.sendError(err) {
+						return
+					}
+				}
+			}
+
+		case <-m.doneCh:
+			return
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package tail
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// appendLine appends a line of text to the file at path
+func appendLine(t *testing.T, path, text string) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(text + "\n"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// nextLine waits for a line from src or fails the test after a timeout
+func nextLine(t *testing.T, src Source) *Line {
+	select {
+	case line := <-src.Lines():
+		return line
+	case err := <-src.Errors():
+		t.Fatalf("unexpected error: %s", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for line")
+	}
+	return nil
+}
+
+func TestMulti(t *testing.T) {
+	t.Run("merges lines and labels them by source", func(t *testing.T) {
+		m := NewMulti()
+		m.Add("a", NewMemory("a1", "a2"))
+		m.Add("b", NewMemory("b1"))
+		m.Close()
+
+		lines := []string{}
+		for line := range m.Lines() {
+			lines = append(lines, line.Source+":"+line.Text)
+		}
+		sort.Strings(lines)
+		assert.Equal(t, []string{"a:a1", "a:a2", "b:b1"}, lines)
+	})
+
+	t.Run("adding a duplicate name is rejected", func(t *testing.T) {
+		m := NewMulti()
+		defer m.Stop()
+		assert.True(t, m.Add("a", NewMemory()))
+		assert.False(t, m.Add("a", NewMemory()))
+	})
+
+	t.Run("stop closes Lines", func(t *testing.T) {
+		m := NewMulti()
+		m.Add("a", NewMemory("a1", "a2"))
+		m.Stop()
+		for range m.Lines() {
+		}
+	})
+
+	t.Run("failed source is stopped while the others are read on", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "dtail")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+
+		a, b := filepath.Join(dir, "a.log"), filepath.Join(dir, "b.log")
+		for _, path := range []string{a, b} {
+			if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		m, err := TailGlob([]string{a, b}, &Config{Poll: true, PollInterval: 10 * time.Millisecond})
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer m.Stop()
+
+		os.Remove(a)
+		select {
+		case err := <-m.Errors():
+			assert.True(t, errors.Is(err, ErrFileRemoved))
+			assert.Contains(t, err.Error(), a)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the removal of a")
+		}
+
+		appendLine(t, b, "b1")
+		assert.Equal(t, &Line{Text: "b1", Source: b}, nextLine(t, m))
+
+		os.Remove(b)
+		assert.Error(t, m.Wait())
+		for range m.Lines() {
+		}
+	})
+}
+
+func TestTailGlob(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dtail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	first := filepath.Join(dir, "first.log")
+	if err := ioutil.WriteFile(first, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := TailGlob([]string{filepath.Join(dir, "*.log")}, &Config{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer m.Stop()
+
+	t.Run("follows files matching the pattern", func(t *testing.T) {
+		appendLine(t, first, "hello")
+		assert.Equal(t, &Line{Text: "hello", Source: first}, nextLine(t, m))
+	})
+
+	t.Run("follows files created after startup", func(t *testing.T) {
+		// the lines written before the pattern is re-evaluated are read as well
+		second := filepath.Join(dir, "second.log")
+		if err := ioutil.WriteFile(second, []byte("world\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, &Line{Text: "world", Source: second}, nextLine(t, m))
+
+		appendLine(t, second, "again")
+		assert.Equal(t, &Line{Text: "again", Source: second}, nextLine(t, m))
+	})
+
+	t.Run("bad pattern returns error", func(t *testing.T) {
+		_, err := TailGlob([]string{"[-]"}, &Config{})
+		assert.Error(t, err)
+	})
+}
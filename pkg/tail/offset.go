@@ -0,0 +1,51 @@
+package tail
+
+import "io"
+
+// readBackChunkSize is the size of the chunks read when scanning a file backwards
+const readBackChunkSize = 4096
+
+// lastLinesOffset returns the offset at which the last n lines of a file of the given size
+// start. The file is scanned backwards from the end, so only the bytes of those lines are read.
+func lastLinesOffset(r io.ReaderAt, size int64, n int) (int64, error) {
+	if n <= 0 || size == 0 {
+		return size, nil
+	}
+
+	buf := make([]byte, readBackChunkSize)
+	end := size
+	// a trailing newline terminates the last line rather than starting a new one
+	skipTrailing := true
+	for end > 0 {
+		start := end - readBackChunkSize
+		if start < 0 {
+			start = 0
+		}
+
+		chunk := buf[:end-start]
+		if _, err := r.ReadAt(chunk, start); err != nil && err != io.EOF {
+			return 0, err
+		}
+
+		for i := len(chunk) - 1; i >= 0; i-- {
+			if chunk[i] != '\n' {
+				skipTrailing = false
+				continue
+			}
+			if skipTrailing {
+				skipTrailing = false
+				continue
+			}
+
+			n--
+			if n == 0 {
+				return start + int64(i) + 1, nil
+			}
+		}
+
+		end = start
+	}
+
+	// the file has fewer than n lines
+	return 0, nil
+}
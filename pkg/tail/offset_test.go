@@ -0,0 +1,47 @@
+package tail
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLastLinesOffset(t *testing.T) {
+	content := "one\ntwo\nthree\n"
+	r := strings.NewReader(content)
+	size := int64(len(content))
+
+	t.Run("offset of the last line", func(t *testing.T) {
+		offset, err := lastLinesOffset(r, size, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "three\n", content[offset:])
+	})
+
+	t.Run("offset of the last two lines", func(t *testing.T) {
+		offset, err := lastLinesOffset(r, size, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, "two\nthree\n", content[offset:])
+	})
+
+	t.Run("more lines than the file has starts at the beginning", func(t *testing.T) {
+		offset, err := lastLinesOffset(r, size, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), offset)
+	})
+
+	t.Run("incomplete last line counts as a line", func(t *testing.T) {
+		content := "one\ntwo\nthr"
+		offset, err := lastLinesOffset(strings.NewReader(content), int64(len(content)), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "thr", content[offset:])
+	})
+
+	t.Run("lines spanning several chunks", func(t *testing.T) {
+		line := strings.Repeat("x", readBackChunkSize/3) + "\n"
+		content := strings.Repeat(line, 10)
+		offset, err := lastLinesOffset(strings.NewReader(content), int64(len(content)), 4)
+		assert.NoError(t, err)
+		assert.Equal(t, strings.Repeat(line, 4), content[offset:])
+	})
+}
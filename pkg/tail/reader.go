@@ -0,0 +1,66 @@
+package tail
+
+import (
+	"bufio"
+	"io"
+)
+
+// Reader is a Source that reads lines from an io.Reader until EOF, at which point the Lines
+// channel is closed. It is used for inputs that cannot be followed, like stdin or a pipe.
+type Reader struct {
+	pipe
+
+	reader *bufio.Reader
+	// closers are closed once reading is done
+	closers []io.Closer
+}
+
+// NewReader returns a new Reader that reads lines from r. Lines are labeled with the given name.
+func NewReader(name string, r io.Reader) *Reader {
+	return newReader(name, r)
+}
+
+// newReader returns a new Reader that reads lines from r and closes the given closers, in
+// order, once it is done reading.
+func newReader(name string, r io.Reader, closers ...io.Closer) *Reader {
+	rd := &Reader{
+		pipe:    newPipe(name),
+		reader:  bufio.NewReader(r),
+		closers: closers,
+	}
+
+	go rd.readAll()
+
+	return rd
+}
+
+// readAll reads lines until the underlying reader is exhausted
+func (r *Reader) readAll() {
+	defer r.close()
+	defer func() {
+		for _, c := range r.closers {
+			c.Close()
+		}
+	}()
+	r.readLines(r.reader)
+}
+
+// readLines delivers every line read from r until EOF. A last line without a trailing newline
+// is delivered as well. It returns false if the Source has been stopped.
+func (p *pipe) readLines(r *bufio.Reader) bool {
+	for {
+		b, err := r.ReadBytes('\n')
+		if len(b) > 0 {
+			if !p.send(string(b)) {
+				return false
+			}
+		}
+
+		if err != nil {
+			if err != io.EOF {
+				return p.sendError(err)
+			}
+			return true
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package tail
+
+import (
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// collect reads every line from a Source until its Lines channel is closed
+func collect(src Source) []string {
+	lines := []string{}
+	for line := range src.Lines() {
+		lines = append(lines, line.Text)
+	}
+	return lines
+}
+
+func TestReader(t *testing.T) {
+	t.Run("reads lines until EOF", func(t *testing.T) {
+		r := NewReader("test", strings.NewReader("one\ntwo\r\nthree"))
+		assert.Equal(t, []string{"one", "two", "three"}, collect(r))
+		assert.NoError(t, r.Wait())
+	})
+
+	t.Run("empty input closes Lines", func(t *testing.T) {
+		r := NewReader("test", strings.NewReader(""))
+		assert.Empty(t, collect(r))
+	})
+
+	t.Run("read errors are delivered on Errors", func(t *testing.T) {
+		r := NewReader("test", iotest.TimeoutReader(strings.NewReader("one\n")))
+		assert.Equal(t, &Line{Text: "one", Source: "test"}, <-r.Lines())
+		assert.Equal(t, iotest.ErrTimeout, r.Wait())
+	})
+
+	t.Run("stop closes Lines", func(t *testing.T) {
+		r := NewReader("test", strings.NewReader("one\ntwo\n"))
+		assert.NoError(t, r.Stop())
+		for range r.Lines() {
+		}
+	})
+}
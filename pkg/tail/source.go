@@ -0,0 +1,150 @@
+package tail
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// Line is a single line read from a Source
+type Line struct {
+	// Text is the content of the line, without its trailing newline
+	Text string
+	// Source identifies where the line was read from (e.g. the path of a file)
+	Source string
+}
+
+// Source is a stream of log lines, e.g. a followed file, stdin or an in-memory buffer.
+type Source interface {
+	// Lines returns the channel on which lines are delivered. The channel is closed once
+	// the Source is exhausted or stopped.
+	Lines() <-chan *Line
+	// Errors returns the channel on which errors are delivered. It is closed along with Lines.
+	// Errors that are a Notice are not fatal, the Source keeps delivering lines.
+	Errors() <-chan error
+	// Stop stops the Source.
+	Stop() error
+	// Wait blocks waiting for any errors returned by the Source, ignoring notices. It returns
+	// nil once the Source is exhausted or stopped.
+	Wait() error
+}
+
+// Notice is a non-fatal error delivered on a Source's Errors channel, reporting a condition
+// that the Source recovered from (e.g. ErrTruncated).
+type Notice struct {
+	Err error
+}
+
+func (n *Notice) Error() string {
+	return n.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (n *Notice) Unwrap() error {
+	return n.Err
+}
+
+// IsNotice reports whether err is, or wraps, a Notice
+func IsNotice(err error) bool {
+	var n *Notice
+	return errors.As(err, &n)
+}
+
+var (
+	_ Source = (*Tail)(nil)
+	_ Source = (*Reader)(nil)
+	_ Source = (*Memory)(nil)
+	_ Source = (*Multi)(nil)
+)
+
+// pipe implements the channel handling shared by every Source. The goroutine producing
+// lines owns the channels and closes them when it returns.
+type pipe struct {
+	// name labels the lines delivered by the Source
+	name   string
+	lines  chan *Line
+	errors chan error
+
+	doneCh    chan struct{}
+	closeOnce sync.Once
+	stopOnce  sync.Once
+}
+
+func newPipe(name string) pipe {
+	return pipe{
+		name:   name,
+		lines:  make(chan *Line),
+		errors: make(chan error),
+		doneCh: make(chan struct{}),
+	}
+}
+
+// Lines returns the channel on which lines are delivered
+func (p *pipe) Lines() <-chan *Line {
+	return p.lines
+}
+
+// Errors returns the channel on which errors are delivered
+func (p *pipe) Errors() <-chan error {
+	return p.errors
+}
+
+// Stop stops the Source. The Lines and Errors channels are closed once the Source has
+// finished cleaning up.
+func (p *pipe) Stop() error {
+	p.stopOnce.Do(func() {
+		close(p.doneCh)
+	})
+	return nil
+}
+
+// Wait blocks waiting for any errors returned by the Source, ignoring notices. It returns
+// nil once the Source is exhausted or stopped.
+func (p *pipe) Wait() error {
+	for err := range p.errors {
+		if !IsNotice(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// send delivers a line to the consumer. It returns false if the Source has been stopped.
+func (p *pipe) send(text string) bool {
+	return p.sendLine(&Line{Text: trimNewline(text), Source: p.name})
+}
+
+// sendLine delivers a Line as is to the consumer. It returns false if the Source has been stopped.
+func (p *pipe) sendLine(line *Line) bool {
+	select {
+	case p.lines <- line:
+		return true
+	case <-p.doneCh:
+		return false
+	}
+}
+
+// sendError delivers an error to the consumer. It returns false if the Source has been stopped.
+func (p *pipe) sendError(err error) bool {
+	select {
+	case p.errors <- err:
+		return true
+	case <-p.doneCh:
+		return false
+	}
+}
+
+// close closes the Lines and Errors channels, signaling the consumer that no more
+// data will be delivered.
+func (p *pipe) close() {
+	p.closeOnce.Do(func() {
+		close(p.lines)
+		close(p.errors)
+	})
+}
+
+// trimNewline removes the line terminator, either "\n" or "\r\n", from the end of a line.
+func trimNewline(line string) string {
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r")
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -14,33 +15,99 @@ import (
 // ErrFileRemoved is an error that will be returned when the tailed file is removed or renamed
 var ErrFileRemoved = errors.New("target file no longer exists")
 
+// ErrTruncated is reported, as a Notice, when the tailed file shrinks below the read position
+// (e.g. after a logrotate `copytruncate`). Reading restarts from the beginning of the file.
+var ErrTruncated = errors.New("file truncated, reading from the start")
+
+// ErrReopened is reported, as a Notice, when the tailed path refers to a new file after the
+// previous one was renamed or removed. Reading continues from the beginning of the new file.
+var ErrReopened = errors.New("file replaced, reading the new file from the start")
+
+// Config describes the configuration for a Tail
 type Config struct {
 	// If true, Tail will keep retrying to open a file after it has been renamed or removed.
 	// This option is useful when you need to handle logoration.
 	Retry bool
+
+	// The following options set where reading starts. By default, only lines written
+	// after the file was opened are read. If several are set, Offset takes precedence
+	// over LastLines, which takes precedence over FromStart.
+
+	// If true, Tail reads the file from the beginning.
+	FromStart bool
+	// If > 0, Tail starts with the last N lines of the file, similar to `tail -n N`.
+	LastLines int
+	// If > 0, Tail starts reading at the given byte offset.
+	Offset int64
+
+	// If set, the read position is recorded in Checkpoints and, on startup, reading resumes
+	// from the recorded position, which takes precedence over the start position options.
+	Checkpoints *Checkpoints
+	// The interval at which the read position is recorded (default 5s). The position is
+	// also recorded when the Tail is stopped.
+	CheckpointInterval time.Duration
+
+	// If true, Tail polls the file for changes instead of relying on filesystem events,
+	// which are not delivered on some filesystems (e.g. NFS, docker-for-mac mounted volumes).
+	// Tail also falls back to polling if it fails to watch the file.
+	Poll bool
+	// The interval at which the file is polled (default 250ms)
+	PollInterval time.Duration
 }
 
+// defaultPollInterval is the interval at which a file is polled when Config.PollInterval is not set
+const defaultPollInterval = 250 * time.Millisecond
+
+// Tail is a Source that follows a file, similar to `tail -f`.
 type Tail struct {
+	pipe
+
 	Config *Config
-	Lines  chan string
-	Errors chan error
 
-	file    *os.File
-	reader  *bufio.Reader
+	file   *os.File
+	reader *bufio.Reader
+	// watcher is nil when polling
 	watcher *fsnotify.Watcher
+	// watchErr is the reason for falling back to polling, if any
+	watchErr error
+	// removed is set once ErrFileRemoved has been reported while polling
+	removed bool
+
+	// offset is the position in the file right after the last complete line that was read
+	offset int64
+	// partial holds the bytes read past offset, i.e. the start of a line whose newline has
+	// not been written yet
+	partial []byte
+
+	// rotated is the file that was being read when the checkpoint was recorded, if it has
+	// since been rotated. Its remaining lines are read before those of the new file.
+	rotated *os.File
+	// rotatedOffset is the position in the rotated file right after the last line delivered
+	rotatedOffset int64
 
-	doneCh chan bool
+	// checkpointed is the time at which the read position was last recorded
+	checkpointed time.Time
+
+	// exitCh is closed once the tail goroutine has cleaned up
+	exitCh chan struct{}
 }
 
+// errStopped is returned when the Tail is stopped while waiting
+var errStopped = errors.New("tail stopped")
+
 // waitForFile will attempt to os.Stat() a file every second until it is present
-// or an error other than IsNotExist is returned.
-func waitForFile(filepath string) error {
+// or an error other than IsNotExist is returned. It gives up if done is closed.
+func waitForFile(filepath string, done <-chan struct{}) error {
 	for {
 		_, err := os.Stat(filepath)
 		if err != nil {
 			if os.IsNotExist(err) {
-				time.Sleep(1 * time.Second)
-				continue
+				select {
+				case <-time.After(1 * time.Second):
+					continue
+				case <-done:
+					return errStopped
+				}
 			} else {
 				return err
 			}
@@ -58,13 +125,14 @@ func (t *Tail) openFile(filepath string) error {
 				return fmt.Errorf("%v: No such file or directory", filepath)
 			}
 
-			err = waitForFile(filepath)
+			err = waitForFile(filepath, t.doneCh)
 			if err != nil {
 				return err
 			}
 
 			return t.openFile(filepath)
 		}
+		return err
 	}
 
 	t.file = f
@@ -73,94 +141,484 @@ func (t *Tail) openFile(filepath string) error {
 	return nil
 }
 
-func (t *Tail) tail() {
-	watcher, err := fsnotify.NewWatcher()
+// seekStart positions the reader at the start position set by the Config
+func (t *Tail) seekStart() error {
+	info, err := t.file.Stat()
 	if err != nil {
-		t.Errors <- fmt.Errorf("fsnotify: %s", err)
+		return err
 	}
-	defer watcher.Close()
-	t.watcher = watcher
+	size := info.Size()
 
-	go func() {
-		for {
-			select {
-			case event := <-t.watcher.Events:
-				if event.Op&fsnotify.Write == fsnotify.Write {
-					b, err := t.reader.ReadBytes('\n')
-					if err != nil {
-						if err == io.EOF {
-							t.Errors <- err
-						}
-					}
-
-					t.Lines <- string(b)
-					continue
-				}
+	offset := size
+	switch {
+	case t.Config.Offset > 0:
+		if t.Config.Offset < size {
+			offset = t.Config.Offset
+		}
+	case t.Config.LastLines > 0:
+		offset, err = lastLinesOffset(t.file, size, t.Config.LastLines)
+		if err != nil {
+			return err
+		}
+	case t.Config.FromStart:
+		offset = 0
+	}
 
-				if event.Op&fsnotify.Remove == fsnotify.Remove || event.Op&fsnotify.Rename == fsnotify.Rename {
-					// stop watching the current file
-					t.watcher.Remove(t.file.Name())
-
-					// if retry enabled, wait for the file
-					if t.Config.Retry {
-						err := waitForFile(t.file.Name())
-						if err != nil {
-							t.Errors <- err
-						}
-						t.watcher.Add(t.file.Name())
-					} else {
-						t.Errors <- ErrFileRemoved
-						continue
-					}
-				}
+	return t.seek(offset)
+}
+
+// seekCheckpoint positions the reader at the position recorded in the checkpoint, if there
+// is one. It returns false if there is no recorded position, in which case the start
+// position set by the Config applies.
+func (t *Tail) seekCheckpoint() (bool, error) {
+	if t.Config.Checkpoints == nil {
+		return false, nil
+	}
+
+	pos, ok := t.Config.Checkpoints.Get(t.name)
+	if !ok {
+		return false, nil
+	}
+
+	info, err := t.file.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	device, inode, ok := fileID(info)
+	if !ok || (device == pos.Device && inode == pos.Inode) {
+		if pos.Offset > info.Size() {
+			// the file was truncated since the position was recorded
+			return true, t.seek(0)
+		}
+		return true, t.seek(pos.Offset)
+	}
+
+	// The file was rotated since the position was recorded. If the previous file can
+	// still be found (e.g. renamed to access.log.1) its remaining lines are read first.
+	// Either way, all of the new file is read.
+	rotated, err := findFile(filepath.Dir(t.name), pos.Device, pos.Inode)
+	if err != nil {
+		return false, err
+	}
+	if rotated != "" {
+		f, err := os.Open(rotated)
+		if err != nil {
+			return false, err
+		}
+		if _, err := f.Seek(pos.Offset, io.SeekStart); err != nil {
+			f.Close()
+			return false, err
+		}
+		t.rotated = f
+		t.rotatedOffset = pos.Offset
+	}
+
+	return true, t.seek(0)
+}
+
+// checkpoint records the current read position, if checkpoints are enabled. Until the
+// rotated file is drained, the position recorded is the one in the rotated file, so that a
+// restart resumes from it.
+func (t *Tail) checkpoint() error {
+	cp := t.Config.Checkpoints
+	if cp == nil {
+		return nil
+	}
+
+	f, offset := t.file, t.offset
+	if t.rotated != nil {
+		f, offset = t.rotated, t.rotatedOffset
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	device, inode, _ := fileID(info)
+	cp.Set(t.name, Position{Device: device, Inode: inode, Offset: offset})
+	t.checkpointed = time.Now()
+	return cp.Flush()
+}
+
+// checkpointInterval returns the interval at which the read position is recorded
+func (t *Tail) checkpointInterval() time.Duration {
+	if t.Config.CheckpointInterval <= 0 {
+		return defaultCheckpointInterval
+	}
+	return t.Config.CheckpointInterval
+}
+
+// checkpointIfDue records the read position if it was last recorded more than the checkpoint
+// interval ago, so that a long catch-up is not read again from the start after a crash. It
+// returns false if the Tail has been stopped.
+func (t *Tail) checkpointIfDue() bool {
+	if t.Config.Checkpoints == nil || time.Since(t.checkpointed) < t.checkpointInterval() {
+		return true
+	}
+	if err := t.checkpoint(); err != nil {
+		return t.sendError(err)
+	}
+	return true
+}
 
-			case err := <-t.watcher.Errors:
-				t.Errors <- err
+// drainRotated delivers the lines remaining in the rotated file. It returns false if the
+// Tail has been stopped, in which case the rotated file is kept so that its position is
+// recorded.
+func (t *Tail) drainRotated() bool {
+	r := bufio.NewReader(t.rotated)
+	for {
+		b, err := r.ReadBytes('\n')
+		if len(b) > 0 {
+			if !t.send(string(b)) {
+				return false
+			}
+			t.rotatedOffset += int64(len(b))
+			if !t.checkpointIfDue() {
+				return false
 			}
 		}
-	}()
 
-	err = t.watcher.Add(t.file.Name())
+		if err != nil {
+			t.rotated.Close()
+			t.rotated = nil
+			if err != io.EOF {
+				return t.sendError(err)
+			}
+			return true
+		}
+	}
+}
+
+// seek positions the reader at the given offset in the file
+func (t *Tail) seek(offset int64) error {
+	_, err := t.file.Seek(offset, io.SeekStart)
 	if err != nil {
-		t.Errors <- err
+		return err
 	}
 
-	<-t.doneCh
+	t.reader.Reset(t.file)
+	t.offset = offset
+	t.partial = nil
+	return nil
 }
 
-// Stop stops the tail and cleans up the channels
-func (t *Tail) Stop() error {
-	err := t.watcher.Remove(t.file.Name())
-	close(t.Lines)
-	close(t.Errors)
-	t.doneCh <- true
-	return err
+// drain delivers every complete line that can be read from the file. An incomplete line at
+// the end of the file is held back until the rest of it, and its newline, are written.
+// The read position is recorded along the way if checkpoints are enabled.
+// It returns false if the Tail has been stopped.
+func (t *Tail) drain() bool {
+	for {
+		b, err := t.reader.ReadBytes('\n')
+		if err != nil {
+			if err != io.EOF {
+				return t.sendError(err)
+			}
+
+			t.partial = append(t.partial, b...)
+			return true
+		}
+
+		t.offset += int64(len(t.partial) + len(b))
+		if len(t.partial) > 0 {
+			b = append(t.partial, b...)
+			t.partial = nil
+		}
+		if !t.send(string(b)) || !t.checkpointIfDue() {
+			return false
+		}
+	}
+}
+
+// flushPartial delivers the incomplete line held back by drain as is. It is used once the
+// file will not be written to anymore. It returns false if the Tail has been stopped.
+func (t *Tail) flushPartial() bool {
+	if len(t.partial) == 0 {
+		return true
+	}
+
+	t.offset += int64(len(t.partial))
+	b := t.partial
+	t.partial = nil
+	return t.send(string(b))
+}
+
+// checkTruncated reseeks to the start of the file if it shrank below the read position,
+// reporting ErrTruncated. It returns false if the Tail has been stopped.
+func (t *Tail) checkTruncated() bool {
+	info, err := t.file.Stat()
+	if err != nil {
+		return t.sendError(err)
+	}
+	if info.Size() >= t.offset+int64(len(t.partial)) {
+		return true
+	}
+
+	if err := t.seek(0); err != nil {
+		return t.sendError(err)
+	}
+	return t.sendError(&Notice{Err: ErrTruncated})
+}
+
+// reopen waits for the tailed path to exist again after it was renamed or removed. If it
+// now refers to a new file, the lines left in the previous file are delivered, then the
+// new file is read from the start, reporting ErrReopened. It returns false if the Tail
+// has been stopped.
+func (t *Tail) reopen() bool {
+	name := t.file.Name()
+	if err := waitForFile(name, t.doneCh); err != nil {
+		if err == errStopped {
+			return false
+		}
+		return t.sendError(err)
+	}
+
+	// deliver anything written to the previous file before it was replaced
+	if !t.drain() {
+		return false
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return t.sendError(err)
+	}
+
+	oldInfo, err := t.file.Stat()
+	if err != nil {
+		f.Close()
+		return t.sendError(err)
+	}
+	newInfo, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return t.sendError(err)
+	}
+
+	if os.SameFile(oldInfo, newInfo) {
+		// the file was moved back in place
+		f.Close()
+	} else {
+		// the previous file is complete, so is its last line
+		if !t.flushPartial() {
+			f.Close()
+			return false
+		}
+
+		t.file.Close()
+		t.file = f
+		if err := t.seek(0); err != nil {
+			return t.sendError(err)
+		}
+		if !t.sendError(&Notice{Err: ErrReopened}) {
+			return false
+		}
+	}
+
+	if t.watcher != nil {
+		if err := t.watcher.Add(name); err != nil {
+			return t.sendError(err)
+		}
+	}
+	return t.drain()
+}
+
+// moved handles the tailed path being renamed or removed. It returns false if the Tail
+// has been stopped.
+func (t *Tail) moved() bool {
+	// if retry enabled, wait for the file
+	if t.Config.Retry {
+		return t.reopen()
+	}
+	return t.sendError(ErrFileRemoved)
+}
+
+// poll checks the file for changes, standing in for filesystem events. It returns false
+// if the Tail has been stopped.
+func (t *Tail) poll() bool {
+	if !t.checkTruncated() || !t.drain() {
+		return false
+	}
+
+	current, err := t.file.Stat()
+	if err != nil {
+		return t.sendError(err)
+	}
+	info, err := os.Stat(t.file.Name())
+	if err == nil && os.SameFile(current, info) {
+		t.removed = false
+		return true
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return t.sendError(err)
+	}
+
+	// report a removed file once, rather than at every poll
+	if !t.Config.Retry && t.removed {
+		return true
+	}
+	t.removed = true
+	return t.moved()
+}
+
+// watch starts watching the file for filesystem events. If that fails, the Tail falls
+// back to polling.
+func (t *Tail) watch() {
+	if t.Config.Poll {
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.watchErr = fmt.Errorf("fsnotify: %s", err)
+		return
+	}
+
+	err = watcher.Add(t.file.Name())
+	if err != nil {
+		watcher.Close()
+		t.watchErr = fmt.Errorf("fsnotify: %s", err)
+		return
+	}
+
+	t.watcher = watcher
+}
+
+func (t *Tail) tail() {
+	defer close(t.exitCh)
+	defer t.close()
+	defer t.file.Close()
+	defer func() {
+		if t.rotated != nil {
+			t.rotated.Close()
+		}
+	}()
+	defer t.checkpoint()
+	t.checkpointed = time.Now()
+
+	// events and watchErrs are nil, and never ready, when polling. pollC is nil otherwise.
+	var (
+		events    chan fsnotify.Event
+		watchErrs chan error
+		pollC     <-chan time.Time
+	)
+	if t.watcher != nil {
+		defer t.watcher.Close()
+		events, watchErrs = t.watcher.Events, t.watcher.Errors
+	} else {
+		if t.watchErr != nil {
+			if !t.sendError(&Notice{Err: fmt.Errorf("%s, falling back to polling", t.watchErr)}) {
+				return
+			}
+		}
+
+		interval := t.Config.PollInterval
+		if interval <= 0 {
+			interval = defaultPollInterval
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		pollC = ticker.C
+	}
+
+	if t.rotated != nil {
+		if !t.drainRotated() {
+			return
+		}
+	}
+
+	// deliver the lines that were already in the file at the start position
+	if !t.drain() {
+		return
+	}
+
+	// checkpointC is nil, and never ready, if checkpoints are disabled
+	var checkpointC <-chan time.Time
+	if t.Config.Checkpoints != nil {
+		ticker := time.NewTicker(t.checkpointInterval())
+		defer ticker.Stop()
+		checkpointC = ticker.C
+	}
+
+	for {
+		select {
+		case event := <-events:
+			if event.Op&fsnotify.Write == fsnotify.Write {
+				if !t.checkTruncated() || !t.drain() {
+					return
+				}
+				continue
+			}
+
+			if event.Op&fsnotify.Remove == fsnotify.Remove || event.Op&fsnotify.Rename == fsnotify.Rename {
+				// stop watching the current file
+				t.watcher.Remove(t.file.Name())
+
+				if !t.moved() {
+					return
+				}
+			}
+
+		case err := <-watchErrs:
+			if !t.sendError(err) {
+				return
+			}
+
+		case <-pollC:
+			if !t.poll() {
+				return
+			}
+
+		case <-checkpointC:
+			if err := t.checkpoint(); err != nil {
+				if !t.sendError(err) {
+					return
+				}
+			}
+
+		case <-t.doneCh:
+			return
+		}
+	}
 }
 
 // TailFile configures a new Tail to follow the specified file.
 func TailFile(filepath string, config *Config) (*Tail, error) {
-	t := &Tail{
-		Config: config,
-		Lines:  make(chan string),
-		Errors: make(chan error),
-	}
+	t := newTail(filepath, config)
 
 	err := t.openFile(filepath)
 	if err != nil {
 		return nil, err
 	}
 
+	resumed, err := t.seekCheckpoint()
+	if err == nil && !resumed {
+		err = t.seekStart()
+	}
+	if err != nil {
+		t.file.Close()
+		return nil, err
+	}
+
+	t.watch()
+
 	go t.tail()
 
 	return t, nil
 }
 
-// Wait blocks waiting for any errors returned by Tail
-func (t *Tail) Wait() error {
-	for {
-		select {
-		case err := <-t.Errors:
-			return err
-		}
+// Stop stops the Tail and waits for it to clean up, which includes recording its position
+// if checkpoints are enabled.
+func (t *Tail) Stop() error {
+	t.pipe.Stop()
+	<-t.exitCh
+	return nil
+}
+
+func newTail(filepath string, config *Config) *Tail {
+	return &Tail{
+		pipe:   newPipe(filepath),
+		Config: config,
+		exitCh: make(chan struct{}),
 	}
 }
@@ -0,0 +1,278 @@
+package tail
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// tempFile creates a file with the given content in a new temporary directory
+func tempFile(t *testing.T, content string) (string, func()) {
+	dir, err := ioutil.TempDir("", "dtail")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "access.log")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestTailStartPosition(t *testing.T) {
+	content := "one\ntwo\nthree\n"
+
+	t.Run("by default only new lines are read", func(t *testing.T) {
+		path, cleanup := tempFile(t, content)
+		defer cleanup()
+
+		tl, err := TailFile(path, &Config{})
+		assert.NoError(t, err)
+		defer tl.Stop()
+
+		appendLine(t, path, "four")
+		assert.Equal(t, "four", nextLine(t, tl).Text)
+	})
+
+	t.Run("from start reads existing lines", func(t *testing.T) {
+		path, cleanup := tempFile(t, content)
+		defer cleanup()
+
+		tl, err := TailFile(path, &Config{FromStart: true})
+		assert.NoError(t, err)
+		defer tl.Stop()
+
+		assert.Equal(t, "one", nextLine(t, tl).Text)
+		assert.Equal(t, "two", nextLine(t, tl).Text)
+		assert.Equal(t, "three", nextLine(t, tl).Text)
+	})
+
+	t.Run("last lines reads the end of the file", func(t *testing.T) {
+		path, cleanup := tempFile(t, content)
+		defer cleanup()
+
+		tl, err := TailFile(path, &Config{LastLines: 2})
+		assert.NoError(t, err)
+		defer tl.Stop()
+
+		assert.Equal(t, "two", nextLine(t, tl).Text)
+		assert.Equal(t, "three", nextLine(t, tl).Text)
+	})
+
+	t.Run("offset starts at the given byte", func(t *testing.T) {
+		path, cleanup := tempFile(t, content)
+		defer cleanup()
+
+		tl, err := TailFile(path, &Config{Offset: 8})
+		assert.NoError(t, err)
+		defer tl.Stop()
+
+		assert.Equal(t, "three", nextLine(t, tl).Text)
+	})
+}
+
+// nextError waits for an error from src or fails the test after a timeout
+func nextError(t *testing.T, src Source) error {
+	select {
+	case err := <-src.Errors():
+		return err
+	case line := <-src.Lines():
+		t.Fatalf("unexpected line: %v", line)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for error")
+	}
+	return nil
+}
+
+func TestTailRotation(t *testing.T) {
+	t.Run("truncated file is read from the start", func(t *testing.T) {
+		path, cleanup := tempFile(t, "one\ntwo\n")
+		defer cleanup()
+
+		tl, err := TailFile(path, &Config{FromStart: true})
+		assert.NoError(t, err)
+		defer tl.Stop()
+		nextLine(t, tl)
+		nextLine(t, tl)
+
+		// logrotate copytruncate
+		if err := ioutil.WriteFile(path, []byte("new\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		err = nextError(t, tl)
+		assert.True(t, IsNotice(err))
+		assert.True(t, errors.Is(err, ErrTruncated))
+		assert.Equal(t, "new", nextLine(t, tl).Text)
+	})
+
+	t.Run("replaced file is reopened when retrying", func(t *testing.T) {
+		path, cleanup := tempFile(t, "one\n")
+		defer cleanup()
+
+		tl, err := TailFile(path, &Config{FromStart: true, Retry: true})
+		assert.NoError(t, err)
+		defer tl.Stop()
+		nextLine(t, tl)
+
+		// logrotate create
+		appendLine(t, path, "two")
+		assert.Equal(t, "two", nextLine(t, tl).Text)
+		if err := os.Rename(path, path+".1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte("new\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		err = nextError(t, tl)
+		assert.True(t, errors.Is(err, ErrReopened))
+		assert.Equal(t, "new", nextLine(t, tl).Text)
+	})
+
+	t.Run("renamed file is an error without retry", func(t *testing.T) {
+		path, cleanup := tempFile(t, "")
+		defer cleanup()
+
+		tl, err := TailFile(path, &Config{})
+		assert.NoError(t, err)
+		defer tl.Stop()
+
+		if err := os.Rename(path, path+".1"); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, ErrFileRemoved, tl.Wait())
+	})
+}
+
+func TestTailWrites(t *testing.T) {
+	t.Run("every line of a burst write is delivered", func(t *testing.T) {
+		path, cleanup := tempFile(t, "")
+		defer cleanup()
+
+		tl, err := TailFile(path, &Config{})
+		assert.NoError(t, err)
+		defer tl.Stop()
+
+		burst := strings.Repeat("line\n", 500)
+		if err := ioutil.WriteFile(path, []byte(burst), 0644); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 500; i++ {
+			assert.Equal(t, "line", nextLine(t, tl).Text)
+		}
+	})
+
+	t.Run("incomplete lines are held back until their newline is written", func(t *testing.T) {
+		path, cleanup := tempFile(t, "")
+		defer cleanup()
+
+		tl, err := TailFile(path, &Config{})
+		assert.NoError(t, err)
+		defer tl.Stop()
+
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		f.WriteString("first half, ")
+		select {
+		case line := <-tl.Lines():
+			t.Fatalf("unexpected line: %v", line)
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		f.WriteString("second half\nnext")
+		assert.Equal(t, "first half, second half", nextLine(t, tl).Text)
+		f.WriteString(" line\n")
+		assert.Equal(t, "next line", nextLine(t, tl).Text)
+	})
+}
+
+func TestTailPoll(t *testing.T) {
+	config := func() *Config {
+		return &Config{Poll: true, PollInterval: 10 * time.Millisecond}
+	}
+
+	t.Run("new lines are read", func(t *testing.T) {
+		path, cleanup := tempFile(t, "")
+		defer cleanup()
+
+		tl, err := TailFile(path, config())
+		assert.NoError(t, err)
+		defer tl.Stop()
+
+		appendLine(t, path, "one")
+		appendLine(t, path, "two")
+		assert.Equal(t, "one", nextLine(t, tl).Text)
+		assert.Equal(t, "two", nextLine(t, tl).Text)
+	})
+
+	t.Run("truncated file is read from the start", func(t *testing.T) {
+		path, cleanup := tempFile(t, "one\ntwo\n")
+		defer cleanup()
+
+		c := config()
+		c.FromStart = true
+		tl, err := TailFile(path, c)
+		assert.NoError(t, err)
+		defer tl.Stop()
+		nextLine(t, tl)
+		nextLine(t, tl)
+
+		if err := ioutil.WriteFile(path, []byte("new\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		assert.True(t, errors.Is(nextError(t, tl), ErrTruncated))
+		assert.Equal(t, "new", nextLine(t, tl).Text)
+	})
+
+	t.Run("replaced file is reopened when retrying", func(t *testing.T) {
+		path, cleanup := tempFile(t, "")
+		defer cleanup()
+
+		c := config()
+		c.Retry = true
+		tl, err := TailFile(path, c)
+		assert.NoError(t, err)
+		defer tl.Stop()
+
+		appendLine(t, path, "old")
+		assert.Equal(t, "old", nextLine(t, tl).Text)
+		if err := os.Rename(path, path+".1"); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte("new\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		assert.True(t, errors.Is(nextError(t, tl), ErrReopened))
+		assert.Equal(t, "new", nextLine(t, tl).Text)
+	})
+
+	t.Run("removed file is reported once without retry", func(t *testing.T) {
+		path, cleanup := tempFile(t, "")
+		defer cleanup()
+
+		tl, err := TailFile(path, config())
+		assert.NoError(t, err)
+		defer tl.Stop()
+
+		os.Remove(path)
+		assert.Equal(t, ErrFileRemoved, nextError(t, tl))
+		select {
+		case err := <-tl.Errors():
+			t.Fatalf("unexpected error: %s", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/perangel/dtail/pkg/clock"
+	"github.com/perangel/dtail/pkg/parser"
+	"github.com/perangel/dtail/pkg/tail"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// flag vars
+	replaySpeed float64
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay [FILE...|-]",
+	Short: "Replay a historical log, driving time from its timestamps",
+	Long: `
+Replay reads historical logs from the start and drives the monitors and reports from
+the timestamps of the requests instead of the wall clock, so that alerts and report
+intervals reflect the traffic as it was logged.
+
+Files are replayed one after another, in the order given (glob patterns are expanded
+in lexical order). Requests are expected in chronological order: a request logged
+earlier than the previous one does not move the clock back.
+
+By default, the log is replayed as fast as possible. Use --speed to replay it at a
+multiple of the pace it was logged at (e.g. 1 for real time, 10 for ten times faster).
+`,
+	RunE: replay,
+}
+
+func init() {
+	replayCmd.Flags().Float64VarP(
+		&replaySpeed,
+		"speed", "s", 0,
+		"Replay speed relative to the pace the log was written at (e.g. 1, 10). 0 replays as fast as possible.",
+	)
+
+	dtailCmd.AddCommand(replayCmd)
+}
+
+// replayPaths returns the files to replay for the given command-line arguments, expanding
+// glob patterns.
+func replayPaths(args []string) ([]string, error) {
+	var paths []string
+	for _, arg := range args {
+		if !strings.ContainsAny(arg, `*?[\`) {
+			paths = append(paths, arg)
+			continue
+		}
+
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", arg, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("%s: no matching files", arg)
+		}
+		sort.Strings(matches)
+		paths = append(paths, matches...)
+	}
+	return paths, nil
+}
+
+// replayer feeds requests to the monitors and the report, advancing a virtual clock to
+// the timestamp of each request.
+type replayer struct {
+	multiple bool
+	parser   parser.Parser
+
+	// clock, monitors and report are set up at the timestamp of the first request
+	clock    *clock.Virtual
+	monitors *requestRateMonitors
+	report   *trafficReport
+
+	// start and logStart are the wall clock and log times at which the replay started,
+	// used to pace the replay when speed > 0
+	speed    float64
+	start    time.Time
+	logStart time.Time
+
+	shutdownCh chan os.Signal
+}
+
+// init sets up the virtual clock, the monitors and the report at the given time
+func (r *replayer) init(t time.Time) {
+	r.clock = clock.NewVirtual(t)
+	r.monitors = newRequestRateMonitors(r.multiple, r.clock)
+	r.report = newTrafficReport(r.multiple)
+	r.clock.Every(reportInterval, func(t time.Time) {
+		r.report.print(t)
+		r.report.reset()
+	})
+
+	r.start = time.Now()
+	r.logStart = t
+}
+
+// advance moves the virtual clock to t, printing the monitor events emitted at each
+// resolution tick along the way.
+func (r *replayer) advance(t time.Time) {
+	for r.clock.Step(t) {
+		r.monitors.printPending()
+	}
+	r.clock.Set(t)
+}
+
+// wait blocks until it is time to replay a request logged at t, according to the replay
+// speed. It returns false if dtail is shut down in the meantime.
+func (r *replayer) wait(t time.Time) bool {
+	if r.speed <= 0 {
+		return true
+	}
+
+	deadline := r.start.Add(time.Duration(float64(t.Sub(r.logStart)) / r.speed))
+	delay := time.Until(deadline)
+	if delay <= 0 {
+		return true
+	}
+
+	select {
+	case <-time.After(delay):
+		return true
+	case <-r.shutdownCh:
+		return false
+	}
+}
+
+// replayLine parses a line and records the request at its timestamp. It returns false if
+// dtail is shut down while waiting to replay it.
+func (r *replayer) replayLine(line *tail.Line) bool {
+	request, err := r.parser.ParseLine(line.Text)
+	if err == parser.ErrSkipLine {
+		return true
+	}
+	if err != nil {
+		log.Println("parser error: ", err)
+		return true
+	}
+	if request.Timestamp == nil {
+		log.Println("replay: skipping request without a timestamp")
+		return true
+	}
+
+	if r.clock == nil {
+		r.init(*request.Timestamp)
+	}
+	if !r.wait(*request.Timestamp) {
+		return false
+	}
+	r.advance(*request.Timestamp)
+
+	r.monitors.inc(line.Source)
+	r.report.record(line.Source, request)
+	return true
+}
+
+// replaySource replays every line of src. It returns false if dtail is shut down before
+// src is exhausted.
+func (r *replayer) replaySource(src tail.Source) (bool, error) {
+	errs := src.Errors()
+	for {
+		select {
+		case line, ok := <-src.Lines():
+			if !ok {
+				return true, nil
+			}
+			if !r.replayLine(line) {
+				src.Stop()
+				return false, nil
+			}
+
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			if tail.IsNotice(err) {
+				fmt.Printf("\033[0;33m%s\033[0m \n", err)
+				continue
+			}
+			src.Stop()
+			return false, err
+
+		case <-r.shutdownCh:
+			src.Stop()
+			return false, nil
+		}
+	}
+}
+
+// finish evaluates the monitors one last time and prints a final report for whatever
+// was collected since the last report interval.
+func (r *replayer) finish() {
+	if r.clock == nil {
+		fmt.Println("No requests to replay.")
+		return
+	}
+	r.monitors.flush()
+	r.report.print(r.clock.Now())
+}
+
+func replay(cmd *cobra.Command, args []string) error {
+	if err := detectFormat(args); err != nil {
+		return err
+	}
+
+	p, err := newParser()
+	if err != nil {
+		return err
+	}
+
+	args = inputArgs(args)
+
+	var paths []string
+	if len(args) == 1 && args[0] == stdinPath {
+		paths = args
+	} else {
+		if paths, err = replayPaths(args); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("\033[0;34mReplaying %s...\033[0m \n", strings.Join(args, ", "))
+
+	r := &replayer{
+		multiple:   len(paths) > 1,
+		parser:     p,
+		speed:      replaySpeed,
+		shutdownCh: make(chan os.Signal, 1),
+	}
+	signal.Notify(r.shutdownCh, os.Interrupt, syscall.SIGTERM)
+
+	for i, path := range paths {
+		if i > 0 {
+			// parsers may hold the state of the log they parse (e.g. the columns of W3C logs)
+			if r.parser, err = newParser(); err != nil {
+				return err
+			}
+		}
+
+		var src tail.Source
+		if path == stdinPath {
+			stdin, err := openStdin()
+			if err != nil {
+				return err
+			}
+			src = tail.NewReader("stdin", stdin)
+		} else {
+			f, err := tail.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			src = f
+		}
+
+		more, err := r.replaySource(src)
+		if err != nil {
+			return err
+		}
+		if !more {
+			break
+		}
+	}
+
+	r.finish()
+	return nil
+}
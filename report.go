@@ -0,0 +1,294 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/perangel/dtail/pkg/clock"
+	"github.com/perangel/dtail/pkg/metrics"
+	"github.com/perangel/dtail/pkg/metrics/collections"
+	"github.com/perangel/dtail/pkg/parser"
+)
+
+// TODO: Move to DSL/query package
+func total4xxResponses(counterMap collections.CounterMap) int64 {
+	total := int64(0)
+	for k, v := range counterMap {
+		// FIXME: Don't skip key on error
+		i, err := strconv.Atoi(k)
+		if err != nil {
+			continue
+		}
+		if 400 <= i && i <= 499 {
+			total += v.Value()
+		}
+	}
+
+	return total
+}
+
+// TODO: Move to DSL/query package
+func total5xxResponses(counterMap collections.CounterMap) int64 {
+	total := int64(0)
+	for k, v := range counterMap {
+		// FIXME: Don't skip key on error
+		i, err := strconv.Atoi(k)
+		if err != nil {
+			continue
+		}
+		if 500 <= i && i <= 599 {
+			total += v.Value()
+		}
+	}
+
+	return total
+
+}
+
+// slowestKeys returns the n keys with the highest average value, given the sum and count of the
+// values at each key, in descending order along with their average (e.g. `app/srv1 (250ms)`).
+func slowestKeys(sums, counts collections.CounterMap, n int, unit string) []string {
+	averages := make(map[string]int64, len(counts))
+	keys := make([]string, 0, len(counts))
+	for k, count := range counts {
+		if count.Value() == 0 {
+			continue
+		}
+		averages[k] = sums[k].Value() / count.Value()
+		keys = append(keys, k)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if averages[keys[i]] != averages[keys[j]] {
+			return averages[keys[i]] > averages[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	if len(keys) > n {
+		keys = keys[:n]
+	}
+
+	slowest := make([]string, len(keys))
+	for i, k := range keys {
+		slowest[i] = fmt.Sprintf("%s (%d%s)", k, averages[k], unit)
+	}
+	return slowest
+}
+
+// latencySummary returns the 50th, 90th and 99th percentiles and the maximum of the durations
+// recorded by h (e.g. `p50: 12ms, p90: 30.5ms, p99: 120ms, max: 1.2s`).
+func latencySummary(h *metrics.Histogram) string {
+	return fmt.Sprintf("p50: %v, p90: %v, p99: %v, max: %v",
+		roundDuration(time.Duration(h.Quantile(0.5))),
+		roundDuration(time.Duration(h.Quantile(0.9))),
+		roundDuration(time.Duration(h.Quantile(0.99))),
+		roundDuration(time.Duration(h.Max())),
+	)
+}
+
+// roundDuration rounds a duration to a precision that suits its magnitude, e.g. 12.35ms rather
+// than 12.345678ms.
+func roundDuration(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	default:
+		return d.Round(time.Microsecond)
+	}
+}
+
+// isAbnormalTermination returns true if an HAProxy termination state (e.g. `CD--`) reports a
+// session that did not end normally, i.e. with a termination cause or a session state.
+func isAbnormalTermination(state string) bool {
+	return len(state) >= 2 && state[:2] != "--"
+}
+
+// trafficReport collects the request statistics that are printed at each report interval.
+type trafficReport struct {
+	// perSource enables the breakdown of requests by source
+	perSource bool
+
+	totalRequests        *metrics.Counter
+	requestsByUser       collections.CounterMap
+	requestsByIP         collections.CounterMap
+	requestsBySection    collections.CounterMap
+	requestsByURI        collections.CounterMap
+	requestsByStatusCode collections.CounterMap
+	requestsBySource     collections.CounterMap
+	requestsByReferer    collections.CounterMap
+	requestsByUserAgent  collections.CounterMap
+
+	// requestsByBackend, the response times by server and the abnormal termination states
+	// are recorded for HAProxy logs
+	requestsByBackend     collections.CounterMap
+	requestsByServer      collections.CounterMap
+	responseTimeByServer  collections.CounterMap
+	requestsByTermination collections.CounterMap
+
+	// latencyBySection records the time taken to serve the requests
+	latencyBySection collections.HistogramMap
+
+	// hasReferers, hasUserAgents, hasBackends and hasDurations are set once a request providing
+	// the field is recorded, so that they are only printed for the log formats that provide them
+	// (e.g. combined or haproxy)
+	hasReferers   bool
+	hasUserAgents bool
+	hasBackends   bool
+	hasDurations  bool
+}
+
+func newTrafficReport(perSource bool) *trafficReport {
+	return &trafficReport{
+		perSource:             perSource,
+		totalRequests:         metrics.NewCounter(),
+		requestsByUser:        collections.NewCounterMap(),
+		requestsByIP:          collections.NewCounterMap(),
+		requestsBySection:     collections.NewCounterMap(),
+		requestsByURI:         collections.NewCounterMap(),
+		requestsByStatusCode:  collections.NewCounterMap(),
+		requestsBySource:      collections.NewCounterMap(),
+		requestsByReferer:     collections.NewCounterMap(),
+		requestsByUserAgent:   collections.NewCounterMap(),
+		requestsByBackend:     collections.NewCounterMap(),
+		requestsByServer:      collections.NewCounterMap(),
+		responseTimeByServer:  collections.NewCounterMap(),
+		requestsByTermination: collections.NewCounterMap(),
+		latencyBySection:      collections.NewHistogramMap(),
+	}
+}
+
+// record adds a request read from the given source to the report
+func (r *trafficReport) record(source string, request *parser.Record) {
+	r.requestsByUser.IncKey(request.AuthUser)
+	r.requestsByIP.IncKey(request.RemoteHost)
+	r.requestsBySection.IncKey(request.Section())
+	r.requestsByURI.IncKey(request.URI)
+	r.requestsByStatusCode.IncKey(fmt.Sprintf("%d", request.StatusCode))
+	r.requestsBySource.IncKey(source)
+	if !isMissing(request.Referer) {
+		r.requestsByReferer.IncKey(request.Referer)
+		r.hasReferers = true
+	}
+	if !isMissing(request.UserAgent) {
+		r.requestsByUserAgent.IncKey(request.UserAgent)
+		r.hasUserAgents = true
+	}
+	if backend, ok := request.Fields["backend_name"].(string); ok {
+		r.recordBackend(backend, request)
+	}
+	if request.Duration != nil {
+		r.latencyBySection.ObserveKey(request.Section(), int64(*request.Duration))
+		r.hasDurations = true
+	}
+	r.totalRequests.Inc(1)
+}
+
+// isMissing reports whether a logged value is missing, i.e. empty or "-" as logged by the
+// combined log format
+func isMissing(value string) bool {
+	return value == "" || value == "-"
+}
+
+// recordBackend adds a request handled by the given HAProxy backend to the report
+func (r *trafficReport) recordBackend(backend string, request *parser.Record) {
+	r.requestsByBackend.IncKey(backend)
+	r.hasBackends = true
+
+	server, _ := request.Fields["server_name"].(string)
+	// the response time is -1 if the server did not respond
+	if responseTime, ok := request.Fields["response_time_ms"].(int64); ok && server != "" && responseTime >= 0 {
+		key := backend + "/" + server
+		r.requestsByServer.IncKey(key)
+		r.responseTimeByServer.AddKey(key, responseTime)
+	}
+
+	if state, ok := request.Fields["termination_state"].(string); ok && isAbnormalTermination(state) {
+		r.requestsByTermination.IncKey(state[:2])
+	}
+}
+
+// latency returns the time taken to serve the requests of all of the sections
+func (r *trafficReport) latency() *metrics.Histogram {
+	latency := metrics.NewHistogram()
+	for _, h := range r.latencyBySection {
+		latency.Add(h)
+	}
+	return latency
+}
+
+// print writes the report to stdout
+func (r *trafficReport) print(t time.Time) {
+	fmt.Println()
+	fmt.Println("Traffic Report:")
+	fmt.Printf("   Current time: %v\n", t)
+	fmt.Printf("   Total Requests: %d\n", r.totalRequests.Value())
+	fmt.Printf("   Top 3 IPs by # of requests: %v\n", r.requestsByIP.TopNKeys(3))
+	fmt.Printf("   Top 3 users by # of requests: %v\n", r.requestsByUser.TopNKeys(3))
+	fmt.Printf("   Top 3 site sections by # of requests: %v\n", r.requestsBySection.TopNKeys(3))
+	fmt.Printf("   Top 3 URIs by # of requests: %v\n", r.requestsByURI.TopNKeys(3))
+	fmt.Printf("   No. of 4xx responses: %v\n", total4xxResponses(r.requestsByStatusCode))
+	fmt.Printf("   No. of 5xx responses: %v\n", total5xxResponses(r.requestsByStatusCode))
+	if r.hasReferers {
+		fmt.Printf("   Top 3 referers by # of requests: %v\n", r.requestsByReferer.TopNKeys(3))
+	}
+	if r.hasUserAgents {
+		fmt.Printf("   Top 3 user agents by # of requests: %v\n", r.requestsByUserAgent.TopNKeys(3))
+	}
+	if r.hasBackends {
+		fmt.Printf("   Top 3 backends by # of requests: %v\n", r.requestsByBackend.TopNKeys(3))
+		fmt.Printf("   Top 3 slowest servers by avg. response time: %v\n", slowestKeys(r.responseTimeByServer, r.requestsByServer, 3, "ms"))
+		fmt.Printf("   Top 3 abnormal termination states by # of requests: %v\n", r.requestsByTermination.TopNKeys(3))
+	}
+	if r.hasDurations {
+		fmt.Printf("   Latency: %s\n", latencySummary(r.latency()))
+		fmt.Println("   Latency of the top 3 site sections by # of requests:")
+		for _, section := range r.requestsBySection.TopNKeys(3) {
+			if h, ok := r.latencyBySection[section]; ok {
+				fmt.Printf("      %s: %s\n", section, latencySummary(h))
+			}
+		}
+	}
+	if r.perSource {
+		fmt.Println("   Requests by source:")
+		for _, source := range r.requestsBySource.TopNKeys(len(r.requestsBySource)) {
+			fmt.Printf("      %s: %d\n", source, r.requestsBySource[source].Value())
+		}
+	}
+	fmt.Println()
+}
+
+// reset resets all of the counters
+func (r *trafficReport) reset() {
+	r.totalRequests.Reset()
+	r.requestsByIP.Reset()
+	r.requestsByUser.Reset()
+	r.requestsBySection.Reset()
+	r.requestsByURI.Reset()
+	r.requestsByStatusCode.Reset()
+	r.requestsBySource.Reset()
+	r.requestsByReferer.Reset()
+	r.requestsByUserAgent.Reset()
+	r.requestsByBackend.Reset()
+	r.requestsByServer.Reset()
+	r.responseTimeByServer.Reset()
+	r.requestsByTermination.Reset()
+	r.latencyBySection.Reset()
+}
+
+// scheduleReports delivers the time of each report interval, according to clk, on the
+// returned channel until the returned function is called. Like a time.Ticker, it drops
+// the ticks that the receiver is not ready for.
+func scheduleReports(clk clock.Clock, interval time.Duration) (<-chan time.Time, func()) {
+	ticks := make(chan time.Time, 1)
+	stop := clk.Every(interval, func(t time.Time) {
+		select {
+		case ticks <- t:
+		default:
+		}
+	})
+	return ticks, stop
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/perangel/dtail/pkg/clock"
+	"github.com/perangel/dtail/pkg/metrics"
+	"github.com/perangel/dtail/pkg/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduleReports(t *testing.T) {
+	start := time.Date(2018, 5, 9, 16, 0, 0, 0, time.UTC)
+
+	t.Run("ticks at every report interval", func(t *testing.T) {
+		clk := clock.NewVirtual(start)
+		ticks, stop := scheduleReports(clk, 10*time.Second)
+		defer stop()
+
+		clk.Advance(9 * time.Second)
+		assert.Empty(t, ticks)
+		clk.Advance(1 * time.Second)
+		assert.Equal(t, start.Add(10*time.Second), <-ticks)
+	})
+
+	t.Run("drops ticks the receiver is not ready for", func(t *testing.T) {
+		clk := clock.NewVirtual(start)
+		ticks, stop := scheduleReports(clk, 10*time.Second)
+		defer stop()
+
+		clk.Advance(30 * time.Second)
+		assert.Equal(t, start.Add(10*time.Second), <-ticks)
+		assert.Empty(t, ticks)
+	})
+
+	t.Run("stop stops the ticks", func(t *testing.T) {
+		clk := clock.NewVirtual(start)
+		ticks, stop := scheduleReports(clk, 10*time.Second)
+		stop()
+
+		clk.Advance(time.Minute)
+		assert.Empty(t, ticks)
+	})
+}
+
+func TestTrafficReport(t *testing.T) {
+	t.Run("records haproxy backends, servers and termination states", func(t *testing.T) {
+		p := parser.NewHAProxyParser()
+		report := newTrafficReport(false)
+		for _, line := range []string{
+			`10.0.1.2:33317 [06/Feb/2009:12:14:14.655] http-in static/srv1 10/0/30/69/109 200 2750 - - ---- 1/1/1/1/0 0/0 "GET /index.html HTTP/1.1"`,
+			`10.0.1.2:33318 [06/Feb/2009:12:14:14.700] http-in static/srv1 10/0/30/31/71 200 2750 - - ---- 1/1/1/1/0 0/0 "GET /index.html HTTP/1.1"`,
+			`10.0.1.3:33319 [06/Feb/2009:12:14:14.800] http-in app/srv2 10/0/30/250/290 200 512 - - ---- 1/1/1/1/0 0/0 "GET /api HTTP/1.1"`,
+			`10.0.1.3:33320 [06/Feb/2009:12:14:15.000] http-in app/srv2 10/0/30/-1/5000 504 194 - - sH-- 1/1/1/1/0 0/0 "GET /api HTTP/1.1"`,
+			`10.0.1.3:33321 [06/Feb/2009:12:14:15.100] http-in app/srv2 10/0/30/-1/30 -1 0 - - CD-- 1/1/1/1/0 0/0 "GET /api HTTP/1.1"`,
+			`10.0.1.4:33322 [06/Feb/2009:12:14:15.200] http-in app/srv3 10/0/30/-1/20 -1 0 - - CD-- 1/1/1/1/0 0/0 "GET /api HTTP/1.1"`,
+		} {
+			request, err := p.ParseLine(line)
+			if !assert.NoError(t, err) {
+				return
+			}
+			report.record("-", request)
+		}
+
+		assert.True(t, report.hasBackends)
+		assert.Equal(t, []string{"app", "static"}, report.requestsByBackend.TopNKeys(3))
+		assert.Equal(t, []string{"app/srv2 (250ms)", "static/srv1 (50ms)"},
+			slowestKeys(report.responseTimeByServer, report.requestsByServer, 3, "ms"))
+		assert.Equal(t, []string{"CD", "sH"}, report.requestsByTermination.TopNKeys(3))
+
+		report.reset()
+		assert.Empty(t, report.requestsByBackend)
+		assert.Empty(t, report.requestsByServer)
+	})
+
+	t.Run("missing referers and user agents are not recorded", func(t *testing.T) {
+		p := parser.NewCombinedParser()
+		report := newTrafficReport(false)
+		for _, line := range []string{
+			`127.0.0.1 - - [09/May/2018:16:00:39 +0000] "GET /report HTTP/1.0" 200 123 "-" "-"`,
+			`127.0.0.1 - - [09/May/2018:16:00:40 +0000] "GET /report HTTP/1.0" 200 123 "-" "curl/7.58.0"`,
+		} {
+			request, err := p.ParseLine(line)
+			if !assert.NoError(t, err) {
+				return
+			}
+			report.record("-", request)
+		}
+
+		assert.False(t, report.hasReferers)
+		assert.Empty(t, report.requestsByReferer)
+		assert.True(t, report.hasUserAgents)
+		assert.Equal(t, []string{"curl/7.58.0"}, report.requestsByUserAgent.TopNKeys(3))
+	})
+
+	t.Run("backends are only recorded for haproxy logs", func(t *testing.T) {
+		report := newTrafficReport(false)
+		report.record("-", parser.NewRecord())
+		assert.False(t, report.hasBackends)
+		assert.False(t, report.hasDurations)
+	})
+
+	t.Run("records latency overall and per section", func(t *testing.T) {
+		report := newTrafficReport(false)
+		for i := 1; i <= 100; i++ {
+			request := parser.NewRecord()
+			request.URI = "/api/users"
+			if i > 90 {
+				request.URI = "/report"
+			}
+			d := time.Duration(i) * time.Millisecond
+			request.Duration = &d
+			report.record("-", request)
+		}
+
+		assert.True(t, report.hasDurations)
+		latency := report.latency()
+		assert.Equal(t, int64(100), latency.Count())
+		assert.InEpsilon(t, float64(50*time.Millisecond), float64(latency.Quantile(0.5)), 0.01)
+		assert.InEpsilon(t, float64(99*time.Millisecond), float64(latency.Quantile(0.99)), 0.01)
+		assert.Equal(t, int64(100*time.Millisecond), latency.Max())
+		assert.Equal(t, int64(90), report.latencyBySection["/api"].Count())
+		assert.Equal(t, int64(90*time.Millisecond), report.latencyBySection["/api"].Max())
+		assert.Equal(t, int64(10), report.latencyBySection["/report"].Count())
+
+		report.reset()
+		assert.Equal(t, int64(0), report.latency().Count())
+		assert.Empty(t, report.latencyBySection)
+	})
+}
+
+func TestLatencySummary(t *testing.T) {
+	h := metrics.NewHistogram()
+	h.Observe(int64(12 * time.Millisecond))
+	assert.Equal(t, "p50: 12ms, p90: 12ms, p99: 12ms, max: 12ms", latencySummary(h))
+}
+
+func TestRoundDuration(t *testing.T) {
+	assert.Equal(t, 1235*time.Millisecond, roundDuration(1234567890))
+	assert.Equal(t, 12350*time.Microsecond, roundDuration(12345678))
+	assert.Equal(t, 123*time.Microsecond, roundDuration(123456))
+}